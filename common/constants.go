@@ -46,6 +46,49 @@ var (
 	LatestFabricInstallerVersion string
 	MinecraftServerPath          string
 	VanillaServerUrl             map[string]string
+	ConsoleBufferSize            int // bytes
+	ConsoleLogMaxSize            int // bytes, 超過就輪替
+	ConsoleLogKeep               int // 保留幾個輪替過的 console log
+	JavaRuntimePaths             []string
+	JavaVersionMap               string
+	CrashLoopMaxFailures         int // window 內 crash 幾次就停用自動重啟
+	CrashLoopWindow              int // 秒
+	RestartBackoffBase           int // 秒
+	RestartBackoffMax            int // 秒
+	StatusPingCacheTTL           int // 秒，Server List Ping 結果的快取時間
+	MetricsInterval              int // 秒，資源用量取樣間隔
+	MetricsHistorySize           int // 每台伺服器保留幾筆取樣
+	CgroupParent                 string
+	CgroupMemoryOverhead         int // MB，memory.max = heap 上限 + overhead
+	PortRanges                   string
+	MinecraftTrashPath           string
+	MinecraftTemplatePath        string
+	MinecraftJarCachePath        string
+	ModIndexURL                  string
+	VanillaManifestURL           string
+	FabricMetaURL                string
+	TrashGracePeriod             int // 小時，刪除的伺服器保留多久可以還原
+	HotBackupTimeout             int // 秒，線上備份等 save-all 完成的時間
+	WorldUploadMaxSize           int // MB，上傳的世界壓縮檔大小上限
+	WorldExtractMaxSize          int // MB，解壓後的大小上限
+	FileTextMaxSize              int // KB，檔案管理可以讀寫的文字檔大小上限
+	FileUploadMaxSize            int // MB，檔案管理單檔上傳上限
+	PropertyHistoryKeep          int // 每台伺服器保留幾筆 server.properties 修改紀錄
+	VersionCatalogRefresh        int // 分鐘，版本清單多久重新抓一次
+)
+
+// 各角色單一伺服器可以設定的最大 heap (MB)
+var (
+	MaxMemoryCommonUser int
+	MaxMemoryAdminUser  int
+	MaxMemoryRootUser   int
+)
+
+// 一般使用者可以設定的 cgroup 上限，不限制 (0) 只有 admin 以上可以設定
+var (
+	MaxCPUWeightCommonUser int
+	MaxCPUQuotaCommonUser  int // 百分比，100 = 一個核心
+	MaxPidsCommonUser      int
 )
 
 var SMTPServer string
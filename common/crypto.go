@@ -59,6 +59,18 @@ func ValidatePasswordAndHash(password string, hash string) bool {
 	return err == nil
 }
 
+// GetSecureRandomString 用 crypto/rand 產生，拿來當密碼用
+func GetSecureRandomString(length int) (string, error) {
+	buf := make([]byte, length)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i := range buf {
+		buf[i] = keyChars[int(buf[i])%len(keyChars)]
+	}
+	return string(buf), nil
+}
+
 func GenerateDeviceIDWithIP(ip string) string {
 	// 1) 隨機 8 byte
 	randBytes := make([]byte, 8)
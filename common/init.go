@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/google/uuid"
 )
@@ -79,9 +80,50 @@ func LoadEnv() {
 	GlobalApiRateLimitNum = GetEnvOrDefault("GLOBAL_API_RATE_LIMIT", 60)
 	GlobalApiRateLimitDuration = int64(GetEnvOrDefault("GLOBAL_API_RATE_LIMIT_DURATION", 60))
 	DCWebHookUrl = GetEnvOrDefaultString("DC_WEBHOOK_URL", "")
+	// 留空就用版本清單裡最新的穩定版，有設定的話固定用這個版本
 	LatestFabricLoaderVersion = GetEnvOrDefaultString("LATEST_FABRIC_LOADER_VERSION", "")
-	LatestFabricInstallerVersion = GetEnvOrDefaultString("LATEST_FABRIC_INSTALLER_VERSION", "1.1.0")
+	LatestFabricInstallerVersion = GetEnvOrDefaultString("LATEST_FABRIC_INSTALLER_VERSION", "")
 	MinecraftServerPath = GetEnvOrDefaultString("MINECRAFT_SERVER_PATH", "./minecraft_servers")
+	ConsoleBufferSize = GetEnvOrDefault("MC_CONSOLE_BUFFER_KB", 256) * 1024
+	ConsoleLogMaxSize = GetEnvOrDefault("MC_CONSOLE_LOG_MAX_MB", 10) * 1024 * 1024
+	ConsoleLogKeep = GetEnvOrDefault("MC_CONSOLE_LOG_KEEP", 10)
+	MaxMemoryCommonUser = GetEnvOrDefault("MC_MAX_MEMORY_COMMON_MB", 4096)
+	MaxMemoryAdminUser = GetEnvOrDefault("MC_MAX_MEMORY_ADMIN_MB", 8192)
+	MaxMemoryRootUser = GetEnvOrDefault("MC_MAX_MEMORY_ROOT_MB", 16384)
+	MaxCPUWeightCommonUser = GetEnvOrDefault("MC_MAX_CPU_WEIGHT_COMMON", 200)
+	MaxCPUQuotaCommonUser = GetEnvOrDefault("MC_MAX_CPU_QUOTA_COMMON", 200)
+	MaxPidsCommonUser = GetEnvOrDefault("MC_MAX_PIDS_COMMON", 2048)
+	// 例如 /usr/lib/jvm/java-8-openjdk/bin/java,/usr/lib/jvm/java-21-openjdk/bin/java
+	JavaRuntimePaths = strings.Split(GetEnvOrDefaultString("JAVA_RUNTIMES", "java"), ",")
+	JavaVersionMap = GetEnvOrDefaultString("JAVA_VERSION_MAP", "1.16.5=8-15,1.17.1=16-21,1.20.4=17-21,*=21")
+	CrashLoopMaxFailures = GetEnvOrDefault("MC_CRASH_LOOP_MAX", 5)
+	CrashLoopWindow = GetEnvOrDefault("MC_CRASH_LOOP_WINDOW", 600)
+	RestartBackoffBase = GetEnvOrDefault("MC_RESTART_BACKOFF_BASE", 5)
+	RestartBackoffMax = GetEnvOrDefault("MC_RESTART_BACKOFF_MAX", 300)
+	StatusPingCacheTTL = GetEnvOrDefault("MC_STATUS_PING_CACHE_SEC", 5)
+	MetricsInterval = GetEnvOrDefault("MC_METRICS_INTERVAL_SEC", 10)
+	MetricsHistorySize = GetEnvOrDefault("MC_METRICS_HISTORY", 360)
+	// 要有寫入權限 (root 或 systemd Delegate=yes)，清空就不使用 cgroup
+	CgroupParent = GetEnvOrDefaultString("CGROUP_PARENT", "/sys/fs/cgroup/mc-servers")
+	CgroupMemoryOverhead = GetEnvOrDefault("MC_CGROUP_MEMORY_OVERHEAD_MB", 512)
+	// 伺服器與 rcon 共用的 port pool，例如 30000-30050,31000-31010
+	PortRanges = GetEnvOrDefaultString("MC_PORT_RANGES", "30000-30050")
+	MinecraftTrashPath = GetEnvOrDefaultString("MINECRAFT_TRASH_PATH", "./minecraft_trash")
+	MinecraftTemplatePath = GetEnvOrDefaultString("MINECRAFT_TEMPLATE_PATH", "./minecraft_templates")
+	// 跟伺服器目錄放在同一個磁碟才能用 hard link，不然每台伺服器都會複製一份
+	MinecraftJarCachePath = GetEnvOrDefaultString("MINECRAFT_JAR_CACHE_PATH", "./minecraft_jar_cache")
+	TrashGracePeriod = GetEnvOrDefault("MC_TRASH_GRACE_HOURS", 72)
+	HotBackupTimeout = GetEnvOrDefault("MC_HOT_BACKUP_TIMEOUT_SEC", 60)
+	WorldUploadMaxSize = GetEnvOrDefault("MC_WORLD_UPLOAD_MAX_MB", 1024)
+	WorldExtractMaxSize = GetEnvOrDefault("MC_WORLD_EXTRACT_MAX_MB", 4096)
+	FileTextMaxSize = GetEnvOrDefault("MC_FILE_TEXT_MAX_KB", 1024)
+	FileUploadMaxSize = GetEnvOrDefault("MC_FILE_UPLOAD_MAX_MB", 256)
+	PropertyHistoryKeep = GetEnvOrDefault("MC_PROPERTY_HISTORY_KEEP", 100)
+	// Modrinth 相容 API 的位址，可以指向自架的鏡像
+	ModIndexURL = strings.TrimRight(GetEnvOrDefaultString("MC_MOD_INDEX_URL", "https://api.modrinth.com/v2"), "/")
+	VanillaManifestURL = GetEnvOrDefaultString("MC_VANILLA_MANIFEST_URL", "https://piston-meta.mojang.com/mc/game/version_manifest_v2.json")
+	FabricMetaURL = strings.TrimRight(GetEnvOrDefaultString("MC_FABRIC_META_URL", "https://meta.fabricmc.net/v2"), "/")
+	VersionCatalogRefresh = GetEnvOrDefault("MC_VERSION_CATALOG_REFRESH_MIN", 360)
 
 	NumPlayer = GetEnvOrDefault("NUM", 5)
 	FoolChance = GetEnvOrDefault("CHANCE", 1000)
@@ -11,6 +11,9 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -30,6 +33,11 @@ func GetRandomIntString(length int) string {
 	return string(key)
 }
 
+// UserAgent 呼叫外部 API (Mojang、Fabric、Modrinth) 時帶的 User-Agent
+func UserAgent() string {
+	return fmt.Sprintf("%s/%s", strings.ReplaceAll(SystemName, " ", "-"), Version)
+}
+
 func GetTimeString() string {
 	now := time.Now()
 	return fmt.Sprintf("%s%d", now.Format("20060102150405"), now.UnixNano()%1e9)
@@ -101,6 +109,38 @@ func GetPortList(start int, end int) []int {
 	return ports
 }
 
+// ParsePortRanges 格式："30000-30050,31000,31010-31020"，回傳排序過、不重複的 port
+func ParsePortRanges(spec string) ([]int, error) {
+	seen := map[int]bool{}
+	var ports []int
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		start, end := item, item
+		if i := strings.Index(item, "-"); i >= 0 {
+			start, end = item[:i], item[i+1:]
+		}
+		s, err1 := strconv.Atoi(strings.TrimSpace(start))
+		e, err2 := strconv.Atoi(strings.TrimSpace(end))
+		if err1 != nil || err2 != nil || s < 1 || e > 65535 || s > e {
+			return nil, fmt.Errorf("invalid port range %q", item)
+		}
+		for p := s; p <= e; p++ {
+			if !seen[p] {
+				seen[p] = true
+				ports = append(ports, p)
+			}
+		}
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("no ports in %q", spec)
+	}
+	sort.Ints(ports)
+	return ports, nil
+}
+
 func Copy(src, dst string) error {
 	err := os.MkdirAll(dst, os.ModePerm) //0777 = os.ModePerm
 	if err != nil {
@@ -122,13 +162,13 @@ func Copy(src, dst string) error {
 		if info.IsDir() {
 			return os.MkdirAll(targetPath, info.Mode())
 		} else {
-			return copyFile(path, targetPath)
+			return CopyFile(path, targetPath)
 		}
 	})
 
 }
 
-func copyFile(src, dst string) error {
+func CopyFile(src, dst string) error {
 	file, err := os.Open(src)
 
 	if err != nil {
@@ -0,0 +1,186 @@
+// controller/files.go
+
+package controller
+
+import (
+	"errors"
+	"go-backend/common"
+	"go-backend/service"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FilePathRequest struct {
+	Path string `json:"path" binding:"required"`
+}
+
+type WriteFileRequest struct {
+	Path    string `json:"path" binding:"required"`
+	Content string `json:"content"`
+}
+
+type RenameFileRequest struct {
+	From string `json:"from" binding:"required"`
+	To   string `json:"to" binding:"required"`
+}
+
+// fileError 檔案管理的錯誤轉成 HTTP 狀態碼
+func fileError(c *gin.Context, op string, err error) {
+	switch {
+	case errors.Is(err, service.ErrFileNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrPathEscape), errors.Is(err, service.ErrFileReadOnly):
+		c.JSON(403, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrFileProtected), errors.Is(err, service.ErrFileExists):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrFileTooLarge):
+		c.JSON(413, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrNotTextFile), errors.Is(err, service.ErrIsDirectory):
+		c.JSON(415, gin.H{"error": err.Error()})
+	default:
+		common.LogError(c.Request.Context(), op+" error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to " + op + "."})
+	}
+}
+
+func (sc *ServerController) ListFiles(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	path := c.DefaultQuery("path", ".")
+	files, err := service.ListServerDir(serverInfo.SystemPath, path)
+	if err != nil {
+		fileError(c, "list files", err)
+		return
+	}
+	c.JSON(200, gin.H{"path": path, "files": files})
+}
+
+func (sc *ServerController) ReadFile(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	content, err := service.ReadServerTextFile(serverInfo.SystemPath, c.Query("path"))
+	if err != nil {
+		fileError(c, "read file", err)
+		return
+	}
+	c.JSON(200, gin.H{"path": c.Query("path"), "content": content})
+}
+
+func (sc *ServerController) WriteFile(c *gin.Context) {
+	var req WriteFileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	if err := sc.svc.WriteFile(serverInfo.ServerID, serverInfo.SystemPath, req.Path, req.Content); err != nil {
+		fileError(c, "write file", err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "File saved."})
+}
+
+// UploadFile multipart 欄位 file，?path= 是目標資料夾，?overwrite=true 才會覆蓋
+func (sc *ServerController) UploadFile(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(common.FileUploadMaxSize+1)<<20)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(413, gin.H{"error": service.ErrFileTooLarge.Error()})
+			return
+		}
+		c.JSON(400, gin.H{"error": "File is required"})
+		return
+	}
+	defer file.Close()
+
+	path, err := sc.svc.UploadFile(serverInfo.ServerID, serverInfo.SystemPath,
+		c.DefaultQuery("path", "."), header.Filename, c.Query("overwrite") == "true", file)
+	if err != nil {
+		fileError(c, "upload file", err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "File uploaded.", "path": path})
+}
+
+func (sc *ServerController) DownloadFile(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	path, err := service.ResolveServerDownload(serverInfo.SystemPath, c.Query("path"))
+	if err != nil {
+		fileError(c, "download file", err)
+		return
+	}
+	c.FileAttachment(path, filepath.Base(path))
+}
+
+func (sc *ServerController) RenameFile(c *gin.Context) {
+	var req RenameFileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	if err := sc.svc.RenameFile(serverInfo.ServerID, serverInfo.SystemPath, req.From, req.To); err != nil {
+		fileError(c, "rename file", err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "File renamed."})
+}
+
+func (sc *ServerController) DeleteFile(c *gin.Context) {
+	var req FilePathRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	if err := sc.svc.DeleteFile(serverInfo.ServerID, serverInfo.SystemPath, req.Path); err != nil {
+		fileError(c, "delete file", err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "File deleted."})
+}
+
+func (sc *ServerController) MakeDir(c *gin.Context) {
+	var req FilePathRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	if err := sc.svc.MakeDir(serverInfo.ServerID, serverInfo.SystemPath, req.Path); err != nil {
+		fileError(c, "create folder", err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Folder created."})
+}
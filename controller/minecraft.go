@@ -8,7 +8,11 @@ import (
 	"go-backend/common"
 	"go-backend/model"
 	"go-backend/service"
+	"io"
+	"net/http"
+	"os"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -25,13 +29,18 @@ func CreateServer(c *gin.Context) {
 	_, uid_str, uid_uint, err := getPayloadAndId(c)
 
 	serverID, err := service.CreateServer(uid_str, req.ServerType, req.ServerVer, req.FabricLoader, req.FabricInstaller)
+	if errors.Is(err, service.ErrNoJavaRuntime) || errors.Is(err, service.ErrVersionNotFound) ||
+		errors.Is(err, service.ErrVersionNoServer) {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		common.LogError(c.Request.Context(), "CreateMinecraftServer error: "+err.Error())
 		c.JSON(500, gin.H{"error": "Failed to create server"})
 		return
 	}
 
-	modelErr := model.AddServerToUser(uid_uint, serverID, req.DisplayName, common.MinecraftServerPath+"/"+serverID)
+	modelErr := model.AddServerToUser(uid_uint, serverID, req.DisplayName, common.MinecraftServerPath+"/"+serverID, req.ServerType, req.ServerVer)
 	if modelErr != nil {
 		common.LogError(c.Request.Context(), "AddServerToUser error: "+modelErr.Error())
 		service.ErrorFileClear(common.MinecraftServerPath + "/" + serverID)
@@ -54,13 +63,30 @@ func GetAllVanillaVersions(c *gin.Context) {
 func GetAllFabricVersions(c *gin.Context) {
 	versions, err := service.GetAllFabricVersions()
 	if len(versions) == 0 || err != nil {
-		common.LogError(c.Request.Context(), "GetAllFabricVersions error: "+err.Error())
+		if err != nil {
+			common.LogError(c.Request.Context(), "GetAllFabricVersions error: "+err.Error())
+		}
 		c.JSON(404, gin.H{"error": ""})
 		return
 	}
 	c.JSON(200, gin.H{"versions": versions})
 }
 
+// GetVersionCatalog kind 是 vanilla / fabric-game / fabric-loader / fabric-installer
+func GetVersionCatalog(c *gin.Context) {
+	versions, err := service.ListCatalogVersions(c.Param("kind"))
+	if errors.Is(err, service.ErrUnknownCatalog) {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		common.LogError(c.Request.Context(), "GetVersionCatalog error: "+err.Error())
+		c.JSON(503, gin.H{"error": "Version list is not available right now."})
+		return
+	}
+	c.JSON(200, gin.H{"versions": versions})
+}
+
 func MyServers(c *gin.Context) {
 	_, _, uid, err := getPayloadAndId(c)
 
@@ -74,7 +100,8 @@ func MyServers(c *gin.Context) {
 	c.JSON(200, servers)
 }
 
-func DeleteServerById(c *gin.Context) {
+// DeleteServerById 停止伺服器、釋放 port，目錄搬進垃圾桶，保留期內可以還原
+func (sc *ServerController) DeleteServerById(c *gin.Context) {
 	serverID := c.Param("server_id")
 	if serverID == "" {
 		c.JSON(400, gin.H{"error": "Server ID is required"})
@@ -83,18 +110,88 @@ func DeleteServerById(c *gin.Context) {
 
 	_, _, id_uint, err := getPayloadAndId(c)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Internal Server Error: " + err.Error()})
+		c.JSON(401, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	err = model.RemoveServerByServerID(id_uint, serverID)
+	serverInfo, err := model.GetServerByID(id_uint, serverID)
 	if err != nil {
-		common.LogDebug(c.Request.Context(), "RemoveServerByServerID error: "+err.Error())
+		common.LogDebug(c.Request.Context(), "Delete, GetServerByID error: "+err.Error())
+		c.JSON(404, gin.H{"error": "Server not found"})
+		return
+	}
+
+	if _, err := sc.svc.DeleteServer(id_uint, serverInfo.ServerID, serverInfo.SystemPath); err != nil {
+		if errors.Is(err, service.ErrBackupInProgress) || errors.Is(err, service.ErrServerDeleting) {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		common.LogError(c.Request.Context(), "DeleteServer error: "+err.Error())
 		c.JSON(500, gin.H{"error": "Failed to delete server"})
 		return
 	}
 
-	c.JSON(200, gin.H{"message": "Server deleted successfully"})
+	c.JSON(200, gin.H{
+		"message":    "Server deleted successfully",
+		"expires_at": service.TrashExpiresAt(time.Now()),
+	})
+}
+
+func (sc *ServerController) ListTrashedServers(c *gin.Context) {
+	_, _, id_uint, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	servers, err := model.GetTrashedServers(id_uint)
+	if err != nil {
+		common.LogDebug(c.Request.Context(), "GetTrashedServers error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to retrieve servers"})
+		return
+	}
+
+	out := make([]gin.H, 0, len(servers))
+	for _, srv := range servers {
+		out = append(out, gin.H{
+			"server_id":    srv.ServerID,
+			"display_name": srv.DisplayName,
+			"deleted_at":   srv.DeletedAt.Time,
+			"expires_at":   service.TrashExpiresAt(srv.DeletedAt.Time),
+		})
+	}
+	c.JSON(200, out)
+}
+
+func (sc *ServerController) RestoreServer(c *gin.Context) {
+	serverID := c.Param("server_id")
+	if serverID == "" {
+		c.JSON(400, gin.H{"error": "Server ID is required"})
+		return
+	}
+
+	_, _, id_uint, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	serverInfo, err := model.GetTrashedServer(id_uint, serverID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "Server not found in trash"})
+		return
+	}
+
+	if err := sc.svc.RestoreServer(id_uint, serverInfo.ServerID, serverInfo.TrashPath, serverInfo.SystemPath); err != nil {
+		if errors.Is(err, service.ErrRestoreConflict) {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		common.LogError(c.Request.Context(), "RestoreServer error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to restore server"})
+		return
+	}
+	c.JSON(200, gin.H{"message": "Server restored"})
 }
 
 func getPayloadAndId(c *gin.Context) (map[string]interface{}, string, uint, error) {
@@ -158,20 +255,191 @@ func (sc *ServerController) GetServerLog(c *gin.Context) {
 	c.JSON(200, gin.H{"logs": logs})
 }
 
-func (sc *ServerController) GetStatus(c *gin.Context) {
-	// Get the server status
+func (sc *ServerController) ListServerLogFiles(c *gin.Context) {
+	serverID := c.Param("server_id")
+	if serverID == "" {
+		c.JSON(400, gin.H{"error": "Server ID is required"})
+		return
+	}
+
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	serverInfo, err := model.GetServerByID(uintID, serverID)
+	if err != nil {
+		common.LogDebug(c.Request.Context(), "Log files, GetServerByID error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to retrieve server log"})
+		return
+	}
+
+	files, err := service.ListConsoleLogs(serverInfo.SystemPath)
+	if err != nil {
+		common.LogError(c.Request.Context(), "ListConsoleLogs error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to retrieve server log"})
+		return
+	}
+	c.JSON(200, gin.H{"files": files})
+}
+
+// GetServerLogHistory 兩種查法：
+// ?file=&offset=&limit= 以 byte offset 分頁；?from=&to=&limit= 以時間範圍查 (RFC3339 或 unix 秒)
+func (sc *ServerController) GetServerLogHistory(c *gin.Context) {
+	serverID := c.Param("server_id")
+	if serverID == "" {
+		c.JSON(400, gin.H{"error": "Server ID is required"})
+		return
+	}
+
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	serverInfo, err := model.GetServerByID(uintID, serverID)
+	if err != nil {
+		common.LogDebug(c.Request.Context(), "Log history, GetServerByID error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to retrieve server log"})
+		return
+	}
+
+	if file := c.Query("file"); file != "" {
+		offset, _ := strconv.ParseInt(c.DefaultQuery("offset", "0"), 10, 64)
+		limit := clampQueryInt(c, "limit", 64*1024, 1024*1024)
+		page, err := service.ReadConsoleLogPage(serverInfo.SystemPath, file, offset, limit)
+		if err != nil {
+			common.LogDebug(c.Request.Context(), "ReadConsoleLogPage error: "+err.Error())
+			c.JSON(400, gin.H{"error": "Failed to read log file"})
+			return
+		}
+		c.JSON(200, page)
+		return
+	}
+
+	from, err := parseTimeQuery(c.Query("from"), time.Time{})
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid from"})
+		return
+	}
+	to, err := parseTimeQuery(c.Query("to"), time.Now())
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid to"})
+		return
+	}
+	limit := clampQueryInt(c, "limit", 500, 5000)
+	lines, truncated, err := service.ReadConsoleLogRange(serverInfo.SystemPath, from, to, limit)
+	if err != nil {
+		common.LogError(c.Request.Context(), "ReadConsoleLogRange error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to read log file"})
+		return
+	}
+	c.JSON(200, gin.H{"lines": lines, "truncated": truncated})
+}
+
+func clampQueryInt(c *gin.Context, key string, def, max int) int {
+	v, err := strconv.Atoi(c.Query(key))
+	if err != nil || v <= 0 {
+		return def
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func parseTimeQuery(raw string, def time.Time) (time.Time, error) {
+	if raw == "" {
+		return def, nil
+	}
+	if sec, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+// StreamServerLog 用 SSE 推送 console，連上時先送一次 backlog
+func (sc *ServerController) StreamServerLog(c *gin.Context) {
 	serverID := c.Param("server_id")
 	if serverID == "" {
 		c.JSON(400, gin.H{"error": "Server ID is required"})
 		return
 	}
-	status, err := sc.svc.Status(serverID)
+
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	serverInfo, err := model.GetServerByID(uintID, serverID)
+	if err != nil {
+		common.LogDebug(c.Request.Context(), "Console, GetServerByID error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to retrieve server log"})
+		return
+	}
+
+	backlog, lines, cancel, err := sc.svc.SubscribeConsole(serverInfo.ServerID)
 	if err != nil {
-		common.LogDebug(c.Request.Context(), "Log, GetStatus error: "+err.Error())
-		c.JSON(500, gin.H{"error": "Failed to get server status, or server not found."})
+		common.LogDebug(c.Request.Context(), "Console, SubscribeConsole error: "+err.Error())
+		c.JSON(404, gin.H{"error": "Server is not running"})
+		return
+	}
+	defer cancel()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("backlog", backlog)
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				// 太慢被踢掉，讓 client 重連
+				return false
+			}
+			c.SSEvent("log", line)
+			return true
+		case <-keepAlive.C:
+			c.SSEvent("ping", "")
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+func (sc *ServerController) GetStatus(c *gin.Context) {
+	// Get the server status，只能看自己的伺服器
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
 		return
 	}
-	c.JSON(200, gin.H{"status": status})
+	serverID := serverInfo.ServerID
+	info := sc.svc.StatusInfo(serverID)
+	resp := gin.H{
+		"status":        info.State,
+		"since":         info.Since,
+		"started_at":    info.StartedAt,
+		"boot_duration": info.BootDuration,
+		"exit_code":     info.ExitCode,
+		"ping":          nil,
+	}
+	// 開好之後才 ping，確認真的能連線
+	if info.State == service.StateRunning {
+		ping, err := sc.svc.Ping(serverID)
+		if err != nil {
+			resp["ping_error"] = err.Error()
+		} else {
+			resp["ping"] = ping
+		}
+	}
+	c.JSON(200, resp)
 
 }
 
@@ -196,9 +464,44 @@ func (sc *ServerController) Start(c *gin.Context) {
 		return
 	}
 
-	srv, err := sc.svc.Start(sid, oid, serverInfo.SystemPath, "2G", "1G", []string{})
+	java, err := service.SelectJavaRuntime(minecraftVersionOf(serverInfo), serverInfo.JavaMajor)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := model.GetRole(uintID)
+	if err != nil {
+		common.LogError(c.Request.Context(), "GetRole error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Server start Failed."})
+		return
+	}
+
+	jvmFlags := service.ParseJvmFlags(serverInfo.JvmFlags)
+	if err := service.ValidateJvmFlags(jvmFlags); err != nil {
+		c.JSON(400, gin.H{"error": err.Error() + ", please update the server's jvm settings"})
+		return
+	}
+
+	launch := service.LaunchConfig{
+		Java:     java.Path,
+		MaxMem:   service.FormatMemory(serverInfo.MaxMemory),
+		MinMem:   service.FormatMemory(serverInfo.MinMemory),
+		JvmFlags: jvmFlags,
+		Args:     []string{},
+		Restart: service.RestartPolicy{
+			Mode:       serverInfo.Restart,
+			MaxRetries: serverInfo.MaxRetries,
+		},
+		Limits: resourceLimitsOf(serverInfo, role),
+	}
+	srv, err := sc.svc.Start(sid, oid, serverInfo.SystemPath, launch)
 	if err != nil {
 		common.LogDebug(c.Request.Context(), "Log, StartServer error: "+err.Error())
+		if errors.Is(err, service.ErrServerDeleting) {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
 		if !errors.Is(err, service.ErrAlreadyRunning) && !errors.Is(err, service.ErrNotFound) && !errors.Is(err, service.ErrMaxReached) {
 			common.LogError(c.Request.Context(), "Log, StartServer error: "+err.Error())
 		}
@@ -270,18 +573,150 @@ func (sc *ServerController) GetServerProperties(c *gin.Context) {
 	c.JSON(200, gin.H{"message": "Property Get.", "property": texts})
 }
 
-type SendCommandRequest struct {
-	Command string `json:"command" binding:"required"`
+// GetTypedProperties 依 schema 轉型後的值，schema 不認得的 key 放在 extra
+func (sc *ServerController) GetTypedProperties(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	values, extra, err := service.TypedProperties(serverInfo.SystemPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.JSON(404, gin.H{"error": "server.properties not found, start the server once first"})
+			return
+		}
+		common.LogError(c.Request.Context(), "TypedProperties error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to get server properties."})
+		return
+	}
+	c.JSON(200, gin.H{"properties": values, "extra": extra})
 }
 
-func (sc *ServerController) SendCommand(c *gin.Context) {
-	var req SendCommandRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+// PatchProperties body 是 {"key": value}，全部驗證通過才會寫入
+func (sc *ServerController) PatchProperties(c *gin.Context) {
+	var req map[string]any
+	if err := c.ShouldBindJSON(&req); err != nil || len(req) == 0 {
 		c.JSON(400, gin.H{"error": "Invalid request"})
 		return
 	}
+	serverInfo, uintID, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	result, err := sc.svc.PatchProperties(serverInfo.ServerID, serverInfo.SystemPath, req, uintID)
+	if err != nil {
+		var verr *service.PropertyValidationError
+		if errors.As(err, &verr) {
+			c.JSON(400, gin.H{"error": "Invalid properties", "errors": verr.Errors})
+			return
+		}
+		common.LogError(c.Request.Context(), "PatchProperties error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to update server properties."})
+		return
+	}
+	c.JSON(200, result)
+}
+
+func (sc *ServerController) ListPropertyHistory(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	revs, err := service.ListPropertyRevisions(serverInfo.ServerID)
+	if err != nil {
+		common.LogError(c.Request.Context(), "ListPropertyRevisions error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to list property history."})
+		return
+	}
+	c.JSON(200, gin.H{"revisions": revs})
+}
+
+// revisionParam 解析路徑或 query 裡的 revision id，失敗時已經回應
+func revisionParam(c *gin.Context, raw string) (uint, bool) {
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(400, gin.H{"error": "Invalid revision id"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func propertyHistoryError(c *gin.Context, op string, err error) {
+	switch {
+	case errors.Is(err, service.ErrRevisionNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrServerRunning):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		common.LogError(c.Request.Context(), op+" error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to " + op + "."})
+	}
+}
+
+func (sc *ServerController) GetPropertyRevision(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	id, ok := revisionParam(c, c.Param("revision_id"))
+	if !ok {
+		return
+	}
+	rev, err := service.GetPropertyRevision(serverInfo.ServerID, id)
+	if err != nil {
+		propertyHistoryError(c, "get property revision", err)
+		return
+	}
+	c.JSON(200, rev)
+}
+
+// DiffPropertyRevisions ?from=&to=
+func (sc *ServerController) DiffPropertyRevisions(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	from, ok := revisionParam(c, c.Query("from"))
+	if !ok {
+		return
+	}
+	to, ok := revisionParam(c, c.Query("to"))
+	if !ok {
+		return
+	}
+	diff, err := service.DiffPropertyRevisions(serverInfo.ServerID, from, to)
+	if err != nil {
+		propertyHistoryError(c, "diff property revisions", err)
+		return
+	}
+	c.JSON(200, gin.H{"from": from, "to": to, "diff": diff})
+}
+
+func (sc *ServerController) RollbackProperties(c *gin.Context) {
+	serverInfo, uintID, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	id, ok := revisionParam(c, c.Param("revision_id"))
+	if !ok {
+		return
+	}
+	if err := sc.svc.RollbackProperties(serverInfo.ServerID, serverInfo.SystemPath, id, uintID); err != nil {
+		propertyHistoryError(c, "rollback properties", err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Properties rolled back."})
+}
+
+func GetPropertySchema(c *gin.Context) {
+	c.JSON(200, gin.H{"schema": service.PropertySchema()})
+}
 
+func ListJvmPresets(c *gin.Context) {
+	c.JSON(200, gin.H{"presets": service.JvmPresets})
+}
+
+func (sc *ServerController) GetJvmSettings(c *gin.Context) {
 	sid := c.Param("server_id")
 	if sid == "" {
 		c.JSON(400, gin.H{"error": "Server ID is required"})
@@ -296,20 +731,44 @@ func (sc *ServerController) SendCommand(c *gin.Context) {
 
 	serverInfo, err := model.GetServerByID(uintID, sid)
 	if err != nil {
+		common.LogDebug(c.Request.Context(), "Jvm, GetServerByID error: "+err.Error())
 		c.JSON(500, gin.H{"error": "Failed to get server information."})
 		return
 	}
 
-	err = sc.svc.SendCommand(serverInfo.ServerID, req.Command)
+	role, err := model.GetRole(uintID)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to send command to server."})
+		common.LogError(c.Request.Context(), "GetRole error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to get server information."})
 		return
 	}
 
-	c.JSON(200, gin.H{"message": "Command sent successfully."})
+	c.JSON(200, gin.H{
+		"max_memory":       serverInfo.MaxMemory,
+		"min_memory":       serverInfo.MinMemory,
+		"jvm_flags":        service.ParseJvmFlags(serverInfo.JvmFlags),
+		"memory_limit":     service.MaxMemoryForRole(role),
+		"min_memory_limit": service.MinHeapMB,
+		"presets":          service.JvmPresets,
+	})
 }
 
-func (sc *ServerController) Backup(c *gin.Context) {
+type UpdateJvmSettingsRequest struct {
+	MaxMemory int      `json:"max_memory" binding:"required"`
+	MinMemory int      `json:"min_memory" binding:"required"`
+	JvmFlags  []string `json:"jvm_flags"`
+	Preset    string   `json:"preset"`
+}
+
+// UpdateJvmSettings 有帶 preset 的話會用 preset 的 flags 取代 jvm_flags，重開伺服器後生效
+func (sc *ServerController) UpdateJvmSettings(c *gin.Context) {
+	var req UpdateJvmSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
 	sid := c.Param("server_id")
 	if sid == "" {
 		c.JSON(400, gin.H{"error": "Server ID is required"})
@@ -324,38 +783,59 @@ func (sc *ServerController) Backup(c *gin.Context) {
 
 	serverInfo, err := model.GetServerByID(uintID, sid)
 	if err != nil {
+		common.LogDebug(c.Request.Context(), "Jvm, GetServerByID error: "+err.Error())
 		c.JSON(500, gin.H{"error": "Failed to get server information."})
 		return
 	}
 
-	err = sc.svc.Backup(serverInfo.ServerID, serverInfo.SystemPath)
+	flags := req.JvmFlags
+	if req.Preset != "" {
+		preset, ok := service.GetJvmPreset(req.Preset)
+		if !ok {
+			c.JSON(400, gin.H{"error": "Unknown preset"})
+			return
+		}
+		flags = preset.Flags
+	}
 
+	role, err := model.GetRole(uintID)
 	if err != nil {
-		if !errors.Is(err, service.ErrServerRunning) {
-			common.LogError(c.Request.Context(), "Backup error: "+err.Error())
-		}
-		r_id := c.Request.Context().Value(common.RequestIdKey)
-		c.JSON(500, gin.H{"error": "Failed to backup server. Request id: " + r_id.(string)})
+		common.LogError(c.Request.Context(), "GetRole error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to update jvm settings."})
 		return
 	}
 
-	c.Status(200)
+	if err := service.ValidateJvmSettings(role, req.MaxMemory, req.MinMemory, flags); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 
-}
+	err = model.UpdateServerJvmSettings(uintID, serverInfo.ServerID, req.MaxMemory, req.MinMemory, service.JoinJvmFlags(flags))
+	if err != nil {
+		common.LogError(c.Request.Context(), "UpdateServerJvmSettings error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to update jvm settings."})
+		return
+	}
 
-type UploadPropertyRequest struct {
-	Texts string `json:"texts" binding:"required"`
+	c.JSON(200, gin.H{"message": "JVM settings updated, restart the server to apply."})
 }
 
-func (sc *ServerController) UploadProperty(c *gin.Context) {
-	var req UploadPropertyRequest
+// minecraftVersionOf 舊資料沒有存版本，改從 server id 拆
+func minecraftVersionOf(info *model.UserMinecraftServer) string {
+	if info.ServerVer != "" {
+		return info.ServerVer
+	}
+	return service.ServerVersionFromID(info.ServerID)
+}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
-		c.JSON(400, gin.H{"error": "Invalid request"})
-		return
+func serverTypeOf(info *model.UserMinecraftServer) string {
+	if info.ServerType != "" {
+		return info.ServerType
 	}
+	return service.ServerTypeFromID(info.ServerID)
+}
 
+func (sc *ServerController) GetJavaRuntime(c *gin.Context) {
 	sid := c.Param("server_id")
 	if sid == "" {
 		c.JSON(400, gin.H{"error": "Server ID is required"})
@@ -370,16 +850,1010 @@ func (sc *ServerController) UploadProperty(c *gin.Context) {
 
 	serverInfo, err := model.GetServerByID(uintID, sid)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Upload Error: " + err.Error()})
+		common.LogDebug(c.Request.Context(), "Java, GetServerByID error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to get server information."})
 		return
 	}
 
-	err = service.ReplaceProperty(serverInfo.SystemPath, req.Texts)
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Upload Error: " + err.Error()})
+	version := minecraftVersionOf(serverInfo)
+	required, maxMajor := service.RequiredJavaRange(version)
+	resp := gin.H{
+		"minecraft_version": version,
+		"required_major":    required,
+		"max_major":         maxMajor, // 0 = 沒有上限
+		"override":          serverInfo.JavaMajor,
+		"runtimes":          service.ListJavaRuntimes(),
+	}
+	if java, err := service.SelectJavaRuntime(version, serverInfo.JavaMajor); err == nil {
+		resp["selected"] = java
+	} else {
+		resp["error"] = err.Error()
+	}
+	c.JSON(200, resp)
+}
+
+type SetJavaRuntimeRequest struct {
+	JavaMajor int `json:"java_major"` // 0 = 自動
+}
+
+func (sc *ServerController) SetJavaRuntime(c *gin.Context) {
+	var req SetJavaRuntimeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	sid := c.Param("server_id")
+	if sid == "" {
+		c.JSON(400, gin.H{"error": "Server ID is required"})
+		return
+	}
+
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	serverInfo, err := model.GetServerByID(uintID, sid)
+	if err != nil {
+		common.LogDebug(c.Request.Context(), "Java, GetServerByID error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to get server information."})
+		return
+	}
+
+	if req.JavaMajor < 0 {
+		c.JSON(400, gin.H{"error": "Invalid java version"})
+		return
+	}
+	if _, err := service.SelectJavaRuntime(minecraftVersionOf(serverInfo), req.JavaMajor); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := model.UpdateServerJavaMajor(uintID, serverInfo.ServerID, req.JavaMajor); err != nil {
+		common.LogError(c.Request.Context(), "UpdateServerJavaMajor error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to update java runtime."})
+		return
+	}
+	c.JSON(200, gin.H{"message": "Java runtime updated, restart the server to apply."})
+}
+
+// GetRestartPolicy 回傳重啟策略以及最近幾次結束的紀錄
+func (sc *ServerController) GetRestartPolicy(c *gin.Context) {
+	sid := c.Param("server_id")
+	if sid == "" {
+		c.JSON(400, gin.H{"error": "Server ID is required"})
+		return
+	}
+
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	serverInfo, err := model.GetServerByID(uintID, sid)
+	if err != nil {
+		common.LogDebug(c.Request.Context(), "Restart, GetServerByID error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to get server information."})
+		return
+	}
+
+	exits, err := model.GetServerExits(serverInfo.ServerID, 20)
+	if err != nil {
+		common.LogError(c.Request.Context(), "GetServerExits error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to get server information."})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"policy": service.RestartPolicy{Mode: serverInfo.Restart, MaxRetries: serverInfo.MaxRetries},
+		"exits":  exits,
+	})
+}
+
+func (sc *ServerController) SetRestartPolicy(c *gin.Context) {
+	var req service.RestartPolicy
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	sid := c.Param("server_id")
+	if sid == "" {
+		c.JSON(400, gin.H{"error": "Server ID is required"})
+		return
+	}
+
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	serverInfo, err := model.GetServerByID(uintID, sid)
+	if err != nil {
+		common.LogDebug(c.Request.Context(), "Restart, GetServerByID error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to get server information."})
+		return
+	}
+
+	if err := service.ValidateRestartPolicy(req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := model.UpdateServerRestartPolicy(uintID, serverInfo.ServerID, req.Mode, req.MaxRetries); err != nil {
+		common.LogError(c.Request.Context(), "UpdateServerRestartPolicy error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to update restart policy."})
+		return
+	}
+	c.JSON(200, gin.H{"message": "Restart policy updated, applies on next start."})
+}
+
+// resourceLimitsOf 套用擁有者角色的上限後實際會生效的限制
+func resourceLimitsOf(info *model.UserMinecraftServer, role int) service.ResourceLimits {
+	return service.ClampResourceLimits(role, service.ResourceLimits{
+		CPUWeight: info.CPUWeight,
+		CPUQuota:  info.CPUQuota,
+		MemoryMax: service.MemoryLimitFor(info.MaxMemory),
+		PidsMax:   info.PidsMax,
+	})
+}
+
+func (sc *ServerController) GetResourceLimits(c *gin.Context) {
+	sid := c.Param("server_id")
+	if sid == "" {
+		c.JSON(400, gin.H{"error": "Server ID is required"})
+		return
+	}
+
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	serverInfo, err := model.GetServerByID(uintID, sid)
+	if err != nil {
+		common.LogDebug(c.Request.Context(), "Limits, GetServerByID error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to get server information."})
+		return
+	}
+
+	role, err := model.GetRole(uintID)
+	if err != nil {
+		common.LogError(c.Request.Context(), "GetRole error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to get server information."})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"enabled":    service.CgroupsEnabled(),
+		"limits":     resourceLimitsOf(serverInfo, role),
+		"role_limit": service.ResourceLimitsForRole(role),
+	})
+}
+
+type UpdateResourceLimitsRequest struct {
+	CPUWeight int `json:"cpu_weight" binding:"required"`
+	CPUQuota  int `json:"cpu_quota"`
+	PidsMax   int `json:"pids_max"`
+}
+
+// UpdateResourceLimits 記憶體上限跟著 JVM 的 max_memory 走，這裡不另外設定
+func (sc *ServerController) UpdateResourceLimits(c *gin.Context) {
+	var req UpdateResourceLimitsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	sid := c.Param("server_id")
+	if sid == "" {
+		c.JSON(400, gin.H{"error": "Server ID is required"})
+		return
+	}
+
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	serverInfo, err := model.GetServerByID(uintID, sid)
+	if err != nil {
+		common.LogDebug(c.Request.Context(), "Limits, GetServerByID error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to get server information."})
+		return
+	}
+
+	// 不限制只有 admin 以上可以設定
+	if req.CPUQuota == 0 || req.PidsMax == 0 {
+		if _, ok := requireAdmin(c); !ok {
+			return
+		}
+	}
+	role, err := model.GetRole(uintID)
+	if err != nil {
+		common.LogError(c.Request.Context(), "GetRole error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to update resource limits."})
+		return
+	}
+	if err := service.ValidateResourceLimits(role, req.CPUWeight, req.CPUQuota, req.PidsMax); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := model.UpdateServerResourceLimits(uintID, serverInfo.ServerID, req.CPUWeight, req.CPUQuota, req.PidsMax); err != nil {
+		common.LogError(c.Request.Context(), "UpdateServerResourceLimits error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to update resource limits."})
+		return
+	}
+	c.JSON(200, gin.H{"message": "Resource limits updated, restart the server to apply."})
+}
+
+func (sc *ServerController) GetMetrics(c *gin.Context) {
+	sid := c.Param("server_id")
+	if sid == "" {
+		c.JSON(400, gin.H{"error": "Server ID is required"})
+		return
+	}
+
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	serverInfo, err := model.GetServerByID(uintID, sid)
+	if err != nil {
+		common.LogDebug(c.Request.Context(), "Metrics, GetServerByID error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to get server information."})
+		return
+	}
+
+	current, history, err := sc.svc.Metrics(serverInfo.ServerID)
+	if err != nil {
+		// 沒有在 manager 裡代表很久沒開了，沒有資料
+		c.JSON(200, gin.H{"current": nil, "history": []service.MetricsSample{}})
+		return
+	}
+	c.JSON(200, gin.H{"current": current, "history": history})
+}
+
+type SendCommandRequest struct {
+	Command string `json:"command" binding:"required"`
+}
+
+func (sc *ServerController) SendCommand(c *gin.Context) {
+	var req SendCommandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	sid := c.Param("server_id")
+	if sid == "" {
+		c.JSON(400, gin.H{"error": "Server ID is required"})
+		return
+	}
+
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	serverInfo, err := model.GetServerByID(uintID, sid)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to get server information."})
+		return
+	}
+
+	result, err := sc.svc.SendCommand(serverInfo.ServerID, req.Command)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			c.JSON(404, gin.H{"error": "Server is not running."})
+			return
+		}
+		common.LogError(c.Request.Context(), "SendCommand error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to send command to server."})
+		return
+	}
+
+	// 走 stdin 時沒有輸出，要看 console
+	c.JSON(200, gin.H{"message": "Command sent successfully.", "output": result.Output, "via": result.Via})
+}
+
+func (sc *ServerController) Backup(c *gin.Context) {
+	sid := c.Param("server_id")
+	if sid == "" {
+		c.JSON(400, gin.H{"error": "Server ID is required"})
+		return
+	}
+
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	serverInfo, err := model.GetServerByID(uintID, sid)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to get server information."})
+		return
+	}
+
+	manifest, err := sc.svc.Backup(serverInfo.ServerID, serverInfo.SystemPath, service.BackupOptions{
+		MCVersion:  minecraftVersionOf(serverInfo),
+		ServerType: serverTypeOf(serverInfo),
+		Source:     "manual",
+		Retention:  backupRetentionOf(serverInfo),
+	})
+
+	if err != nil {
+		if errors.Is(err, service.ErrServerRunning) || errors.Is(err, service.ErrBackupInProgress) {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrSaveTimeout) {
+			c.JSON(504, gin.H{"error": err.Error()})
+			return
+		}
+		common.LogError(c.Request.Context(), "Backup error: "+err.Error())
+		r_id := c.Request.Context().Value(common.RequestIdKey)
+		c.JSON(500, gin.H{"error": "Failed to backup server. Request id: " + r_id.(string)})
+		return
+	}
+
+	c.JSON(200, manifest)
+
+}
+
+func backupRetentionOf(info *model.UserMinecraftServer) service.BackupRetention {
+	return service.BackupRetention{
+		KeepLast:   info.KeepLast,
+		KeepDaily:  info.KeepDaily,
+		KeepWeekly: info.KeepWeekly,
+	}
+}
+
+// ownedServer 取得 :server_id 並確認是自己的伺服器，失敗時已經回應
+func ownedServer(c *gin.Context) (*model.UserMinecraftServer, uint, bool) {
+	sid := c.Param("server_id")
+	if sid == "" {
+		c.JSON(400, gin.H{"error": "Server ID is required"})
+		return nil, 0, false
+	}
+
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return nil, 0, false
+	}
+
+	serverInfo, err := model.GetServerByID(uintID, sid)
+	if err != nil {
+		common.LogDebug(c.Request.Context(), "GetServerByID error: "+err.Error())
+		c.JSON(404, gin.H{"error": "Server not found"})
+		return nil, 0, false
+	}
+	return serverInfo, uintID, true
+}
+
+func (sc *ServerController) ListBackups(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	backups, err := service.ListBackups(serverInfo.SystemPath)
+	if err != nil {
+		common.LogError(c.Request.Context(), "ListBackups error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to list backups."})
+		return
+	}
+	c.JSON(200, gin.H{"backups": backups, "retention": backupRetentionOf(serverInfo)})
+}
+
+func (sc *ServerController) DownloadBackup(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	manifest, path, err := service.GetBackup(serverInfo.SystemPath, c.Param("name"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "Backup not found"})
+		return
+	}
+	c.Header("X-Checksum-Sha256", manifest.SHA256)
+	c.FileAttachment(path, manifest.Name+".zip")
+}
+
+func (sc *ServerController) DeleteBackup(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	if err := service.DeleteBackup(serverInfo.ServerID, serverInfo.SystemPath, c.Param("name")); err != nil {
+		if errors.Is(err, service.ErrBackupNotFound) {
+			c.JSON(404, gin.H{"error": "Backup not found"})
+			return
+		}
+		if errors.Is(err, service.ErrBackupInProgress) {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		common.LogError(c.Request.Context(), "DeleteBackup error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to delete backup."})
+		return
+	}
+	c.JSON(200, gin.H{"message": "Backup deleted."})
+}
+
+func (sc *ServerController) RestoreBackup(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	err := sc.svc.RestoreBackup(serverInfo.ServerID, serverInfo.SystemPath, c.Param("name"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrBackupNotFound):
+			c.JSON(404, gin.H{"error": "Backup not found"})
+		case errors.Is(err, service.ErrServerRunning), errors.Is(err, service.ErrBackupInProgress):
+			c.JSON(409, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrBackupCorrupted):
+			c.JSON(422, gin.H{"error": err.Error()})
+		default:
+			common.LogError(c.Request.Context(), "RestoreBackup error: "+err.Error())
+			c.JSON(500, gin.H{"error": "Failed to restore backup."})
+		}
+		return
+	}
+	c.JSON(200, gin.H{"message": "Backup restored."})
+}
+
+func (sc *ServerController) SetBackupRetention(c *gin.Context) {
+	var req service.BackupRetention
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+	serverInfo, uintID, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	if err := service.ValidateBackupRetention(req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := model.UpdateServerBackupRetention(uintID, serverInfo.ServerID, req.KeepLast, req.KeepDaily, req.KeepWeekly); err != nil {
+		common.LogError(c.Request.Context(), "UpdateServerBackupRetention error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to update backup retention."})
+		return
+	}
+	c.JSON(200, gin.H{"message": "Backup retention updated."})
+}
+
+// backupError 備份相關的錯誤統一轉成 HTTP 狀態碼
+func backupError(c *gin.Context, op string, err error) {
+	switch {
+	case errors.Is(err, service.ErrBackupNotFound), errors.Is(err, service.ErrSnapshotNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrServerRunning), errors.Is(err, service.ErrBackupInProgress):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrBackupCorrupted):
+		c.JSON(422, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrSaveTimeout):
+		c.JSON(504, gin.H{"error": err.Error()})
+	default:
+		common.LogError(c.Request.Context(), op+" error: "+err.Error())
+		r_id := c.Request.Context().Value(common.RequestIdKey)
+		c.JSON(500, gin.H{"error": "Failed to " + op + ". Request id: " + r_id.(string)})
+	}
+}
+
+func (sc *ServerController) CreateSnapshot(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	snap, err := sc.svc.Snapshot(serverInfo.ServerID, serverInfo.SystemPath, service.BackupOptions{
+		MCVersion: minecraftVersionOf(serverInfo),
+		Source:    "manual",
+	})
+	if err != nil {
+		backupError(c, "create snapshot", err)
+		return
+	}
+	c.JSON(200, snap)
+}
+
+func (sc *ServerController) ListSnapshots(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	snaps, err := service.ListSnapshots(serverInfo.SystemPath)
+	if err != nil {
+		backupError(c, "list snapshots", err)
+		return
+	}
+	c.JSON(200, gin.H{"snapshots": snaps, "retention": backupRetentionOf(serverInfo)})
+}
+
+func (sc *ServerController) VerifySnapshots(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	report, err := sc.svc.VerifySnapshots(serverInfo.ServerID, serverInfo.SystemPath)
+	if err != nil {
+		backupError(c, "verify snapshots", err)
+		return
+	}
+	c.JSON(200, gin.H{"ok": len(report.Missing) == 0 && len(report.Corrupted) == 0, "report": report})
+}
+
+// PruneSnapshots 套用跟 zip 備份相同的保留設定
+func (sc *ServerController) PruneSnapshots(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	report, err := sc.svc.PruneSnapshots(serverInfo.ServerID, serverInfo.SystemPath, backupRetentionOf(serverInfo))
+	if err != nil {
+		backupError(c, "prune snapshots", err)
+		return
+	}
+	c.JSON(200, report)
+}
+
+func (sc *ServerController) RestoreSnapshot(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	if err := sc.svc.RestoreSnapshot(serverInfo.ServerID, serverInfo.SystemPath, c.Param("snapshot_id")); err != nil {
+		backupError(c, "restore snapshot", err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Snapshot restored."})
+}
+
+// ImportWorld multipart 欄位 file，zip / tar / tar.gz 都可以
+func (sc *ServerController) ImportWorld(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	// 多留 1MB 給 multipart 的其他部分
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(common.WorldUploadMaxSize+1)<<20)
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(413, gin.H{"error": service.ErrWorldTooLarge.Error()})
+			return
+		}
+		c.JSON(400, gin.H{"error": "World archive is required"})
+		return
+	}
+	defer file.Close()
+
+	result, err := sc.svc.ImportWorld(serverInfo.ServerID, serverInfo.SystemPath, file)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrWorldTooLarge):
+			c.JSON(413, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrInvalidWorld):
+			c.JSON(400, gin.H{"error": err.Error()})
+		default:
+			backupError(c, "import world", err)
+		}
+		return
+	}
+	c.JSON(200, result)
+}
+
+// ExportWorld ?format=zip (預設) 或 tar.gz
+func (sc *ServerController) ExportWorld(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	format := c.DefaultQuery("format", "zip")
+	if format != "zip" && format != "tar.gz" {
+		c.JSON(400, gin.H{"error": "format must be zip or tar.gz"})
+		return
+	}
+	export, err := sc.svc.OpenWorldExport(serverInfo.ServerID, serverInfo.SystemPath)
+	if err != nil {
+		backupError(c, "export world", err)
+		return
+	}
+	defer export.Close()
+
+	filename := fmt.Sprintf("%s-%s.%s", export.LevelName, time.Now().Format("20060102-150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if format == "zip" {
+		c.Header("Content-Type", "application/zip")
+	} else {
+		c.Header("Content-Type", "application/gzip")
+	}
+	c.Status(200)
+	// header 已經送出，失敗只能記 log，client 會拿到不完整的檔案
+	if err := export.WriteTo(c.Writer, format); err != nil {
+		common.LogError(c.Request.Context(), "ExportWorld error: "+err.Error())
+	}
+}
+
+type UploadPropertyRequest struct {
+	Texts string `json:"texts" binding:"required"`
+}
+
+func (sc *ServerController) UploadProperty(c *gin.Context) {
+	var req UploadPropertyRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	sid := c.Param("server_id")
+	if sid == "" {
+		c.JSON(400, gin.H{"error": "Server ID is required"})
+		return
+	}
+
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	serverInfo, err := model.GetServerByID(uintID, sid)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Upload Error: " + err.Error()})
+		return
+	}
+
+	err = service.ReplaceProperties(serverInfo.ServerID, serverInfo.SystemPath, req.Texts, uintID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Upload Error: " + err.Error()})
 		return
 	}
 
 	c.JSON(200, gin.H{"message": "Uploaded."})
 
 }
+
+// requireAdmin 管理員 (含 root) 才能操作，失敗時已經回應
+func requireAdmin(c *gin.Context) (uint, bool) {
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return 0, false
+	}
+	role, err := model.GetRole(uintID)
+	if err != nil || role < common.RoleAdminUser {
+		c.JSON(403, gin.H{"error": "forbidden"})
+		return 0, false
+	}
+	return uintID, true
+}
+
+func (sc *ServerController) ListPorts(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+	info, err := sc.svc.ListPorts()
+	if err != nil {
+		common.LogError(c.Request.Context(), "ListPorts error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to list ports."})
+		return
+	}
+	c.JSON(200, info)
+}
+
+type PortRequest struct {
+	Port int    `json:"port" binding:"required"`
+	Note string `json:"note"`
+}
+
+func (sc *ServerController) ReservePort(c *gin.Context) {
+	var req PortRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+	if err := sc.svc.ReservePort(req.Port, req.Note); err != nil {
+		switch {
+		case errors.Is(err, service.ErrPortNotInPool):
+			c.JSON(400, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrPortInUse):
+			c.JSON(409, gin.H{"error": err.Error()})
+		default:
+			c.JSON(500, gin.H{"error": "Failed to reserve port."})
+		}
+		return
+	}
+	c.JSON(200, gin.H{"message": "Port reserved."})
+}
+
+func (sc *ServerController) ReleasePort(c *gin.Context) {
+	var req PortRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+	if err := sc.svc.ReleasePort(req.Port); err != nil {
+		switch {
+		case errors.Is(err, service.ErrPortNotAllocated):
+			c.JSON(404, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrPortInUse):
+			c.JSON(409, gin.H{"error": "Port is held by a running server."})
+		default:
+			c.JSON(500, gin.H{"error": "Failed to release port."})
+		}
+		return
+	}
+	c.JSON(200, gin.H{"message": "Port released."})
+}
+
+type CloneServerRequest struct {
+	DisplayName string `json:"display_name" binding:"required"`
+	service.CloneOptions
+}
+
+func (sc *ServerController) CloneServer(c *gin.Context) {
+	var req CloneServerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	sid := c.Param("server_id")
+	if sid == "" {
+		c.JSON(400, gin.H{"error": "Server ID is required"})
+		return
+	}
+
+	_, oid, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	serverInfo, err := model.GetServerByID(uintID, sid)
+	if err != nil {
+		common.LogDebug(c.Request.Context(), "Clone, GetServerByID error: "+err.Error())
+		c.JSON(404, gin.H{"error": "Server not found"})
+		return
+	}
+
+	newID, err := sc.svc.CloneServer(serverInfo.ServerID, serverInfo.SystemPath, oid, serverTypeOf(serverInfo), minecraftVersionOf(serverInfo), req.CloneOptions)
+	if err != nil {
+		if errors.Is(err, service.ErrCloneWhileRunning) {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		common.LogError(c.Request.Context(), "CloneServer error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to clone server"})
+		return
+	}
+
+	clone := *serverInfo
+	clone.ServerType = serverTypeOf(serverInfo)
+	clone.ServerVer = minecraftVersionOf(serverInfo)
+	path := common.MinecraftServerPath + "/" + newID
+	if err := model.CloneServerToUser(&clone, newID, req.DisplayName, path); err != nil {
+		common.LogError(c.Request.Context(), "CloneServerToUser error: "+err.Error())
+		// 目錄是這次新建的 (newServerDir 保證)，可以直接清掉
+		service.ErrorFileClear(path)
+		_ = model.RemoveServerMods(newID)
+		c.JSON(500, gin.H{"error": "Failed to add server to user"})
+		return
+	}
+
+	c.JSON(200, gin.H{"server_id": newID})
+}
+
+type SaveTemplateRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Public      bool   `json:"public"`
+	service.CloneOptions
+}
+
+func (sc *ServerController) SaveTemplate(c *gin.Context) {
+	var req SaveTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	sid := c.Param("server_id")
+	if sid == "" {
+		c.JSON(400, gin.H{"error": "Server ID is required"})
+		return
+	}
+
+	_, oid, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	serverInfo, err := model.GetServerByID(uintID, sid)
+	if err != nil {
+		common.LogDebug(c.Request.Context(), "Template, GetServerByID error: "+err.Error())
+		c.JSON(404, gin.H{"error": "Server not found"})
+		return
+	}
+
+	// 只有管理員可以建立公開模板
+	if req.Public {
+		role, err := model.GetRole(uintID)
+		if err != nil || role < common.RoleAdminUser {
+			c.JSON(403, gin.H{"error": "Only admins can publish templates"})
+			return
+		}
+	}
+
+	key := "tpl-" + oid + "-" + common.GetRandomString(8)
+	path, err := sc.svc.SaveTemplate(serverInfo.ServerID, serverInfo.SystemPath, key, req.CloneOptions)
+	if err != nil {
+		if errors.Is(err, service.ErrCloneWhileRunning) {
+			c.JSON(409, gin.H{"error": err.Error()})
+			return
+		}
+		common.LogError(c.Request.Context(), "SaveTemplate error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to save template"})
+		return
+	}
+
+	tpl := &model.MinecraftServerTemplate{
+		Key:         key,
+		OwnerID:     uintID,
+		Name:        req.Name,
+		Description: req.Description,
+		ServerType:  serverTypeOf(serverInfo),
+		ServerVer:   minecraftVersionOf(serverInfo),
+		Path:        path,
+		Public:      req.Public,
+	}
+	if err := model.AddTemplate(tpl); err != nil {
+		common.LogError(c.Request.Context(), "AddTemplate error: "+err.Error())
+		_ = service.RemoveTemplateDir(path)
+		c.JSON(500, gin.H{"error": "Failed to save template"})
+		return
+	}
+
+	c.JSON(200, tpl)
+}
+
+func (sc *ServerController) ListTemplates(c *gin.Context) {
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	templates, err := model.GetVisibleTemplates(uintID)
+	if err != nil {
+		common.LogError(c.Request.Context(), "GetVisibleTemplates error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to list templates"})
+		return
+	}
+	c.JSON(200, templates)
+}
+
+type CreateFromTemplateRequest struct {
+	DisplayName string `json:"display_name" binding:"required"`
+}
+
+func (sc *ServerController) CreateFromTemplate(c *gin.Context) {
+	var req CreateFromTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	tid, err := strconv.ParseUint(c.Param("template_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid template ID"})
+		return
+	}
+
+	_, oid, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	tpl, err := model.GetVisibleTemplate(uintID, uint(tid))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "Template not found"})
+		return
+	}
+
+	serverID, err := service.CreateFromTemplate(tpl.Path, oid, tpl.ServerType, tpl.ServerVer)
+	if err != nil {
+		if errors.Is(err, service.ErrNoJavaRuntime) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		common.LogError(c.Request.Context(), "CreateFromTemplate error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to create server"})
+		return
+	}
+
+	path := common.MinecraftServerPath + "/" + serverID
+	if err := model.AddServerToUser(uintID, serverID, req.DisplayName, path, tpl.ServerType, tpl.ServerVer); err != nil {
+		common.LogError(c.Request.Context(), "AddServerToUser error: "+err.Error())
+		// 目錄是這次新建的 (newServerDir 保證)，可以直接清掉
+		service.ErrorFileClear(path)
+		c.JSON(500, gin.H{"error": "Failed to add server to user"})
+		return
+	}
+
+	c.JSON(200, gin.H{"server_id": serverID})
+}
+
+// DeleteTemplate 只有建立者或管理員可以刪
+func (sc *ServerController) DeleteTemplate(c *gin.Context) {
+	tid, err := strconv.ParseUint(c.Param("template_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid template ID"})
+		return
+	}
+
+	_, _, uintID, err := getPayloadAndId(c)
+	if err != nil {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	tpl, err := model.GetTemplate(uint(tid))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "Template not found"})
+		return
+	}
+	if tpl.OwnerID != uintID {
+		role, err := model.GetRole(uintID)
+		if err != nil || role < common.RoleAdminUser {
+			c.JSON(404, gin.H{"error": "Template not found"})
+			return
+		}
+	}
+
+	if err := model.RemoveTemplate(tpl.ID); err != nil {
+		common.LogError(c.Request.Context(), "RemoveTemplate error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to delete template"})
+		return
+	}
+	if err := service.RemoveTemplateDir(tpl.Path); err != nil {
+		common.LogError(c.Request.Context(), "RemoveTemplateDir error: "+err.Error())
+	}
+	c.JSON(200, gin.H{"message": "Template deleted"})
+}
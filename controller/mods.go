@@ -0,0 +1,138 @@
+// controller/mods.go
+
+package controller
+
+import (
+	"errors"
+	"go-backend/common"
+	"go-backend/service"
+	"io"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InstallModRequest struct {
+	Project   string `json:"project"`    // project id 或 slug
+	VersionID string `json:"version_id"` // 空的就裝最新的相容版本
+}
+
+type UpdateModsRequest struct {
+	ProjectID string `json:"project_id"` // 空的就全部更新
+}
+
+type RemoveModRequest struct {
+	ProjectID string `json:"project_id" binding:"required"`
+	Force     bool   `json:"force"`
+}
+
+// modError 模組相關的錯誤轉成 HTTP 狀態碼
+func modError(c *gin.Context, op string, err error) {
+	switch {
+	case errors.Is(err, service.ErrModNotFound), errors.Is(err, service.ErrModNotInstalled):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrModsUnsupported), errors.Is(err, service.ErrInvalidModRequest):
+		c.JSON(400, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrServerRunning), errors.Is(err, service.ErrModIncompatible),
+		errors.Is(err, service.ErrModRequired):
+		c.JSON(409, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrModNoVersion), errors.Is(err, service.ErrModClientOnly):
+		c.JSON(422, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrModIndexHTTP), errors.Is(err, service.ErrModHashMismatch):
+		common.LogError(c.Request.Context(), op+" error: "+err.Error())
+		c.JSON(502, gin.H{"error": err.Error()})
+	default:
+		common.LogError(c.Request.Context(), op+" error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to " + op + "."})
+	}
+}
+
+// SearchMods 用伺服器的遊戲版本過濾
+func (sc *ServerController) SearchMods(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	if serverTypeOf(serverInfo) != "Fabric" {
+		modError(c, "search mods", service.ErrModsUnsupported)
+		return
+	}
+	result, err := service.SearchMods(c.Query("q"), minecraftVersionOf(serverInfo),
+		clampQueryInt(c, "offset", 0, 10000), clampQueryInt(c, "limit", 20, 100))
+	if err != nil {
+		modError(c, "search mods", err)
+		return
+	}
+	c.JSON(200, result)
+}
+
+func (sc *ServerController) ListMods(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	mods, err := service.ListInstalledMods(serverInfo.ServerID, serverInfo.SystemPath)
+	if err != nil {
+		modError(c, "list mods", err)
+		return
+	}
+	c.JSON(200, gin.H{"mods": mods})
+}
+
+func (sc *ServerController) InstallMod(c *gin.Context) {
+	var req InstallModRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	changes, err := sc.svc.InstallMod(serverInfo.ServerID, serverInfo.SystemPath,
+		serverTypeOf(serverInfo), minecraftVersionOf(serverInfo), req.Project, req.VersionID)
+	if err != nil {
+		modError(c, "install mod", err)
+		return
+	}
+	c.JSON(200, gin.H{"installed": changes})
+}
+
+func (sc *ServerController) UpdateMods(c *gin.Context) {
+	var req UpdateModsRequest
+	// 沒有 body 也可以，代表全部更新
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	changes, err := sc.svc.UpdateMods(serverInfo.ServerID, serverInfo.SystemPath,
+		serverTypeOf(serverInfo), minecraftVersionOf(serverInfo), req.ProjectID)
+	if err != nil {
+		modError(c, "update mods", err)
+		return
+	}
+	c.JSON(200, gin.H{"updated": changes})
+}
+
+func (sc *ServerController) RemoveMod(c *gin.Context) {
+	var req RemoveModRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	if err := sc.svc.RemoveMod(serverInfo.ServerID, serverInfo.SystemPath, req.ProjectID, req.Force); err != nil {
+		modError(c, "remove mod", err)
+		return
+	}
+	c.JSON(200, gin.H{"message": "Mod removed."})
+}
@@ -0,0 +1,78 @@
+// controller/players.go
+
+package controller
+
+import (
+	"errors"
+	"go-backend/common"
+	"go-backend/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// playerListError 名單相關的錯誤轉成 HTTP 狀態碼
+func playerListError(c *gin.Context, op string, err error) {
+	switch {
+	case errors.Is(err, service.ErrUnknownPlayerList), errors.Is(err, service.ErrEntryNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrPlayerUnknown):
+		c.JSON(422, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrOpLevelRunning):
+		c.JSON(409, gin.H{"error": err.Error()})
+	default:
+		common.LogError(c.Request.Context(), op+" error: "+err.Error())
+		c.JSON(500, gin.H{"error": "Failed to " + op + "."})
+	}
+}
+
+func (sc *ServerController) ListPlayerList(c *gin.Context) {
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	entries, err := service.ListPlayerList(serverInfo.SystemPath, c.Param("list"))
+	if err != nil {
+		playerListError(c, "list "+c.Param("list"), err)
+		return
+	}
+	c.JSON(200, gin.H{"list": c.Param("list"), "entries": entries})
+}
+
+func (sc *ServerController) AddPlayerListEntry(c *gin.Context) {
+	sc.changePlayerList(c, true)
+}
+
+func (sc *ServerController) RemovePlayerListEntry(c *gin.Context) {
+	sc.changePlayerList(c, false)
+}
+
+func (sc *ServerController) changePlayerList(c *gin.Context, add bool) {
+	var req service.PlayerListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.LogDebug(c.Request.Context(), "request binding error: "+err.Error())
+		c.JSON(400, gin.H{"error": "Invalid request"})
+		return
+	}
+	serverInfo, _, ok := ownedServer(c)
+	if !ok {
+		return
+	}
+	list := c.Param("list")
+	var result *service.PlayerListResult
+	var err error
+	if add {
+		result, err = sc.svc.AddPlayerListEntry(serverInfo.ServerID, serverInfo.SystemPath, list, req)
+	} else {
+		result, err = sc.svc.RemovePlayerListEntry(serverInfo.ServerID, serverInfo.SystemPath, list, req)
+	}
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPlayerName) || errors.Is(err, service.ErrInvalidIP) ||
+			errors.Is(err, service.ErrInvalidListEntry) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		playerListError(c, "update "+list, err)
+		return
+	}
+	c.JSON(200, result)
+}
@@ -84,6 +84,13 @@ func migrateDB() error {
 		&User{},
 		&UserDevice{},
 		&UserMinecraftServer{},
+		&MinecraftServerState{},
+		&MinecraftServerExit{},
+		&MinecraftPortAllocation{},
+		&MinecraftServerTemplate{},
+		&MinecraftPropertyRevision{},
+		&MinecraftServerMod{},
+		&MinecraftVersion{},
 		&BlockedIP{},
 		&LoginAttempt{},
 		&Book{},
@@ -4,22 +4,67 @@ package model
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type UserMinecraftServer struct {
-	OnwerID     uint      `gorm:"primaryKey;not null" json:"onwer_id"`
-	DisplayName string    `gorm:"size:100;not null" json:"display_name"`
-	ServerID    string    `gorm:"primaryKey;size:32;not null" json:"server_id"`
-	SystemPath  string    `gorm:"size:255;not null" json:"system_path"`
-	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	OnwerID     uint           `gorm:"primaryKey;not null" json:"onwer_id"`
+	DisplayName string         `gorm:"size:100;not null" json:"display_name"`
+	ServerID    string         `gorm:"primaryKey;size:32;not null" json:"server_id"`
+	SystemPath  string         `gorm:"size:255;not null" json:"system_path"`
+	ServerType  string         `gorm:"size:16" json:"server_type"`
+	ServerVer   string         `gorm:"size:32" json:"server_ver"`
+	JavaMajor   int            `gorm:"default:0;not null" json:"java_major"`    // 0 = 依版本自動選
+	MaxMemory   int            `gorm:"default:2048;not null" json:"max_memory"` // MB
+	MinMemory   int            `gorm:"default:1024;not null" json:"min_memory"` // MB
+	JvmFlags    string         `gorm:"type:text" json:"jvm_flags"`              // 以空白分隔
+	Restart     string         `gorm:"size:16;default:'never'" json:"restart"`  // never / on-failure / always
+	MaxRetries  int            `gorm:"default:3;not null" json:"max_retries"`
+	CPUWeight   int            `gorm:"default:100;not null" json:"cpu_weight"`
+	CPUQuota    int            `gorm:"default:0;not null" json:"cpu_quota"` // 百分比，0 = 不限制
+	PidsMax     int            `gorm:"default:1024;not null" json:"pids_max"`
+	KeepLast    int            `gorm:"default:10;not null" json:"backup_keep_last"` // 備份保留規則
+	KeepDaily   int            `gorm:"default:7;not null" json:"backup_keep_daily"`
+	KeepWeekly  int            `gorm:"default:4;not null" json:"backup_keep_weekly"`
+	TrashPath   string         `gorm:"size:255" json:"-"` // 刪除後目錄搬到哪裡
+	CreatedAt   time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at"` // 在垃圾桶裡的伺服器，過了保留期才真的刪掉
 }
 
-func AddServerToUser(userID uint, serverID, displayName string, systemPath string) error {
+func AddServerToUser(userID uint, serverID, displayName string, systemPath string, serverType, serverVer string) error {
 	userServer := UserMinecraftServer{
 		OnwerID:     userID,
 		ServerID:    serverID,
 		DisplayName: displayName,
 		SystemPath:  systemPath,
+		ServerType:  serverType,
+		ServerVer:   serverVer,
+	}
+	return DB.Create(&userServer).Error
+}
+
+// CloneServerToUser 複製伺服器時沿用來源的 JVM / 重啟 / 資源設定
+func CloneServerToUser(src *UserMinecraftServer, serverID, displayName, systemPath string) error {
+	userServer := UserMinecraftServer{
+		OnwerID:     src.OnwerID,
+		ServerID:    serverID,
+		DisplayName: displayName,
+		SystemPath:  systemPath,
+		ServerType:  src.ServerType,
+		ServerVer:   src.ServerVer,
+		JavaMajor:   src.JavaMajor,
+		MaxMemory:   src.MaxMemory,
+		MinMemory:   src.MinMemory,
+		JvmFlags:    src.JvmFlags,
+		Restart:     src.Restart,
+		MaxRetries:  src.MaxRetries,
+		CPUWeight:   src.CPUWeight,
+		CPUQuota:    src.CPUQuota,
+		PidsMax:     src.PidsMax,
+		KeepLast:    src.KeepLast,
+		KeepDaily:   src.KeepDaily,
+		KeepWeekly:  src.KeepWeekly,
 	}
 	return DB.Create(&userServer).Error
 }
@@ -33,10 +78,6 @@ func GetUserServers(userID uint) ([]UserMinecraftServer, error) {
 	return servers, nil
 }
 
-func RemoveServerByServerID(userID uint, serverID string) error {
-	return DB.Where("onwer_id = ? AND server_id = ?", userID, serverID).Delete(&UserMinecraftServer{}).Error
-}
-
 func GetServerByID(userID uint, serverID string) (*UserMinecraftServer, error) {
 	var server UserMinecraftServer
 	err := DB.Where("onwer_id = ? AND server_id = ?", userID, serverID).First(&server).Error
@@ -45,3 +86,106 @@ func GetServerByID(userID uint, serverID string) (*UserMinecraftServer, error) {
 	}
 	return &server, nil
 }
+
+func UpdateServerJvmSettings(userID uint, serverID string, maxMemory, minMemory int, jvmFlags string) error {
+	return DB.Model(&UserMinecraftServer{}).
+		Where("onwer_id = ? AND server_id = ?", userID, serverID).
+		Updates(map[string]interface{}{
+			"max_memory": maxMemory,
+			"min_memory": minMemory,
+			"jvm_flags":  jvmFlags,
+		}).Error
+}
+
+func UpdateServerJavaMajor(userID uint, serverID string, javaMajor int) error {
+	return DB.Model(&UserMinecraftServer{}).
+		Where("onwer_id = ? AND server_id = ?", userID, serverID).
+		Update("java_major", javaMajor).Error
+}
+
+func UpdateServerRestartPolicy(userID uint, serverID string, mode string, maxRetries int) error {
+	return DB.Model(&UserMinecraftServer{}).
+		Where("onwer_id = ? AND server_id = ?", userID, serverID).
+		Updates(map[string]interface{}{
+			"restart":     mode,
+			"max_retries": maxRetries,
+		}).Error
+}
+
+func UpdateServerBackupRetention(userID uint, serverID string, keepLast, keepDaily, keepWeekly int) error {
+	return DB.Model(&UserMinecraftServer{}).
+		Where("onwer_id = ? AND server_id = ?", userID, serverID).
+		Updates(map[string]interface{}{
+			"keep_last":   keepLast,
+			"keep_daily":  keepDaily,
+			"keep_weekly": keepWeekly,
+		}).Error
+}
+
+func UpdateServerResourceLimits(userID uint, serverID string, cpuWeight, cpuQuota, pidsMax int) error {
+	return DB.Model(&UserMinecraftServer{}).
+		Where("onwer_id = ? AND server_id = ?", userID, serverID).
+		Updates(map[string]interface{}{
+			"cpu_weight": cpuWeight,
+			"cpu_quota":  cpuQuota,
+			"pids_max":   pidsMax,
+		}).Error
+}
+
+// TrashServer 軟刪除，一般查詢就查不到了
+func TrashServer(userID uint, serverID, trashPath string) error {
+	return DB.Model(&UserMinecraftServer{}).
+		Where("onwer_id = ? AND server_id = ?", userID, serverID).
+		Updates(map[string]interface{}{
+			"trash_path": trashPath,
+			"deleted_at": time.Now(),
+		}).Error
+}
+
+func GetTrashedServers(userID uint) ([]UserMinecraftServer, error) {
+	var servers []UserMinecraftServer
+	err := DB.Unscoped().Where("onwer_id = ? AND deleted_at IS NOT NULL", userID).Find(&servers).Error
+	if err != nil {
+		return nil, err
+	}
+	return servers, nil
+}
+
+func GetTrashedServer(userID uint, serverID string) (*UserMinecraftServer, error) {
+	var server UserMinecraftServer
+	err := DB.Unscoped().Where("onwer_id = ? AND server_id = ? AND deleted_at IS NOT NULL", userID, serverID).First(&server).Error
+	if err != nil {
+		return nil, err
+	}
+	return &server, nil
+}
+
+func RestoreTrashedServer(userID uint, serverID string) error {
+	return DB.Unscoped().Model(&UserMinecraftServer{}).
+		Where("onwer_id = ? AND server_id = ?", userID, serverID).
+		Updates(map[string]interface{}{
+			"trash_path": "",
+			"deleted_at": nil,
+		}).Error
+}
+
+// GetExpiredTrashedServers 刪除時間早於 before 的伺服器
+func GetExpiredTrashedServers(before time.Time) ([]UserMinecraftServer, error) {
+	var servers []UserMinecraftServer
+	err := DB.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", before).Find(&servers).Error
+	if err != nil {
+		return nil, err
+	}
+	return servers, nil
+}
+
+// ServerIDInUse 垃圾桶裡的也算，不然還原時會撞到
+func ServerIDInUse(serverID string) (bool, error) {
+	var count int64
+	err := DB.Unscoped().Model(&UserMinecraftServer{}).Where("server_id = ?", serverID).Count(&count).Error
+	return count > 0, err
+}
+
+func PurgeServer(userID uint, serverID string) error {
+	return DB.Unscoped().Where("onwer_id = ? AND server_id = ?", userID, serverID).Delete(&UserMinecraftServer{}).Error
+}
@@ -0,0 +1,76 @@
+// model/mod.go
+
+package model
+
+import (
+	"time"
+)
+
+// MinecraftServerMod 透過模組管理裝進 mods/ 的模組，一個 project 只會有一筆
+type MinecraftServerMod struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ServerID      string    `gorm:"size:128;not null;uniqueIndex:idx_server_mod" json:"server_id"`
+	ProjectID     string    `gorm:"size:32;not null;uniqueIndex:idx_server_mod" json:"project_id"`
+	Slug          string    `gorm:"size:128" json:"slug"`
+	Title         string    `gorm:"size:255" json:"title"`
+	VersionID     string    `gorm:"size:32;not null" json:"version_id"`
+	VersionNumber string    `gorm:"size:128" json:"version_number"`
+	FileName      string    `gorm:"size:255;not null" json:"file_name"`
+	SHA512        string    `gorm:"size:128" json:"sha512"`
+	Size          int64     `json:"size"`
+	Dependencies  string    `gorm:"size:1024" json:"dependencies"`               // 必要相依的 project id，逗號分隔
+	AsDependency  bool      `gorm:"not null;default:false" json:"as_dependency"` // 因為其他模組需要才裝的
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func GetServerMods(serverID string) ([]MinecraftServerMod, error) {
+	var mods []MinecraftServerMod
+	err := DB.Where("server_id = ?", serverID).Order("title").Find(&mods).Error
+	if err != nil {
+		return nil, err
+	}
+	return mods, nil
+}
+
+func GetServerMod(serverID, projectID string) (*MinecraftServerMod, error) {
+	var m MinecraftServerMod
+	err := DB.Where("server_id = ? AND project_id = ?", serverID, projectID).First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// SaveServerMod 同一個 project 已經有就更新
+func SaveServerMod(m *MinecraftServerMod) error {
+	existing, err := GetServerMod(m.ServerID, m.ProjectID)
+	if err == nil {
+		m.ID = existing.ID
+		m.CreatedAt = existing.CreatedAt
+	}
+	return DB.Save(m).Error
+}
+
+func RemoveServerMod(serverID, projectID string) error {
+	return DB.Where("server_id = ? AND project_id = ?", serverID, projectID).Delete(&MinecraftServerMod{}).Error
+}
+
+func RemoveServerMods(serverID string) error {
+	return DB.Where("server_id = ?", serverID).Delete(&MinecraftServerMod{}).Error
+}
+
+// CopyServerMods 複製伺服器時連 mods 的紀錄一起帶過去，不然新伺服器的模組沒辦法更新或移除
+func CopyServerMods(srcID, dstID string) error {
+	mods, err := GetServerMods(srcID)
+	if err != nil || len(mods) == 0 {
+		return err
+	}
+	for i := range mods {
+		mods[i].ID = 0
+		mods[i].ServerID = dstID
+		mods[i].CreatedAt = time.Time{}
+		mods[i].UpdatedAt = time.Time{}
+	}
+	return DB.Create(&mods).Error
+}
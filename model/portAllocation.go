@@ -0,0 +1,45 @@
+// model/portAllocation.go
+
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+const (
+	PortKindGame     = "game"
+	PortKindRcon     = "rcon"
+	PortKindReserved = "reserved" // 管理員手動保留，不會分配給伺服器
+)
+
+// MinecraftPortAllocation port pool 中已經被使用的 port
+type MinecraftPortAllocation struct {
+	Port      int       `gorm:"primaryKey;autoIncrement:false" json:"port"`
+	ServerID  string    `gorm:"size:64;index" json:"server_id"` // reserved 時為空
+	Kind      string    `gorm:"size:16;not null" json:"kind"`
+	Note      string    `gorm:"size:255" json:"note"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// SavePortAllocation 同一個 port 直接覆寫，CreatedAt 保留第一次分配的時間
+func SavePortAllocation(alloc *MinecraftPortAllocation) error {
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "port"}},
+		DoUpdates: clause.AssignmentColumns([]string{"server_id", "kind", "note"}),
+	}).Create(alloc).Error
+}
+
+func RemovePortAllocation(port int) error {
+	return DB.Where("port = ?", port).Delete(&MinecraftPortAllocation{}).Error
+}
+
+func GetAllPortAllocations() ([]MinecraftPortAllocation, error) {
+	var allocs []MinecraftPortAllocation
+	err := DB.Order("port").Find(&allocs).Error
+	if err != nil {
+		return nil, err
+	}
+	return allocs, nil
+}
@@ -0,0 +1,66 @@
+// model/propertyRevision.go
+
+package model
+
+import (
+	"time"
+)
+
+// MinecraftPropertyRevision server.properties 每次修改後的完整內容，AuthorID 0 代表不是透過面板改的
+type MinecraftPropertyRevision struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ServerID   string    `gorm:"size:128;index;not null" json:"server_id"`
+	AuthorID   uint      `gorm:"not null;default:0" json:"author_id"`
+	Source     string    `gorm:"size:16;not null" json:"source"`
+	RollbackOf uint      `gorm:"not null;default:0" json:"rollback_of,omitempty"`
+	Content    string    `gorm:"type:text;not null" json:"content,omitempty"`
+	Diff       string    `gorm:"type:text;not null" json:"diff"` // 跟上一版的差異
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func AddPropertyRevision(r *MinecraftPropertyRevision) error {
+	return DB.Create(r).Error
+}
+
+func GetLatestPropertyRevision(serverID string) (*MinecraftPropertyRevision, error) {
+	var r MinecraftPropertyRevision
+	err := DB.Where("server_id = ?", serverID).Order("id desc").First(&r).Error
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func GetPropertyRevision(serverID string, id uint) (*MinecraftPropertyRevision, error) {
+	var r MinecraftPropertyRevision
+	err := DB.Where("server_id = ? AND id = ?", serverID, id).First(&r).Error
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetPropertyRevisions 新的在前，不含完整內容
+func GetPropertyRevisions(serverID string, limit int) ([]MinecraftPropertyRevision, error) {
+	var revs []MinecraftPropertyRevision
+	err := DB.Omit("content").Where("server_id = ?", serverID).Order("id desc").Limit(limit).Find(&revs).Error
+	if err != nil {
+		return nil, err
+	}
+	return revs, nil
+}
+
+// PrunePropertyRevisions 只保留最新的 keep 筆
+func PrunePropertyRevisions(serverID string, keep int) error {
+	var ids []uint
+	err := DB.Model(&MinecraftPropertyRevision{}).Where("server_id = ?", serverID).
+		Order("id desc").Offset(keep).Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	return DB.Where("id IN ?", ids).Delete(&MinecraftPropertyRevision{}).Error
+}
+
+func RemovePropertyRevisions(serverID string) error {
+	return DB.Where("server_id = ?", serverID).Delete(&MinecraftPropertyRevision{}).Error
+}
@@ -0,0 +1,72 @@
+// model/serverState.go
+
+package model
+
+import (
+	"time"
+)
+
+// MinecraftServerState 紀錄 ServerManager 中正在跑的伺服器，後端重啟後靠它還原
+type MinecraftServerState struct {
+	ServerID  string    `gorm:"primaryKey;size:64;not null" json:"server_id"`
+	OwnerID   string    `gorm:"size:32;not null" json:"owner_id"`
+	Pid       int       `gorm:"not null" json:"pid"`
+	Port      int       `gorm:"index;not null" json:"port"`
+	RconPort  int       `json:"rcon_port"` // 0 代表沒有 rcon
+	RconPass  string    `gorm:"size:64" json:"-"`
+	WorkDir   string    `gorm:"size:255;not null" json:"work_dir"`
+	JavaPath  string    `gorm:"size:255" json:"java_path"`
+	MaxMem    string    `gorm:"size:16" json:"max_mem"`
+	MinMem    string    `gorm:"size:16" json:"min_mem"`
+	JvmFlags  string    `gorm:"type:text" json:"jvm_flags"` // JSON 編碼的 []string
+	Args      string    `gorm:"type:text" json:"args"`      // JSON 編碼的 []string
+	Restart   string    `gorm:"size:16" json:"restart"`
+	Retries   int       `json:"retries"`
+	Limits    string    `gorm:"type:text" json:"limits"` // JSON 編碼的 ResourceLimits
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// SaveServerState 新增或覆寫一筆狀態 (以 ServerID 為 key)
+func SaveServerState(state *MinecraftServerState) error {
+	return DB.Save(state).Error
+}
+
+func RemoveServerState(serverID string) error {
+	return DB.Where("server_id = ?", serverID).Delete(&MinecraftServerState{}).Error
+}
+
+func GetAllServerStates() ([]MinecraftServerState, error) {
+	var states []MinecraftServerState
+	err := DB.Find(&states).Error
+	if err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+// MinecraftServerExit 每次 java process 結束都記一筆
+type MinecraftServerExit struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ServerID  string    `gorm:"size:64;index;not null" json:"server_id"`
+	ExitCode  int       `json:"exit_code"`
+	Crashed   bool      `gorm:"not null" json:"crashed"`
+	Requested bool      `gorm:"not null" json:"requested"` // 透過 Stop 結束
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func RecordServerExit(exit *MinecraftServerExit) error {
+	return DB.Create(exit).Error
+}
+
+func RemoveServerExits(serverID string) error {
+	return DB.Where("server_id = ?", serverID).Delete(&MinecraftServerExit{}).Error
+}
+
+func GetServerExits(serverID string, limit int) ([]MinecraftServerExit, error) {
+	var exits []MinecraftServerExit
+	err := DB.Where("server_id = ?", serverID).Order("id desc").Limit(limit).Find(&exits).Error
+	if err != nil {
+		return nil, err
+	}
+	return exits, nil
+}
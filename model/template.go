@@ -0,0 +1,57 @@
+// model/template.go
+
+package model
+
+import (
+	"time"
+)
+
+// MinecraftServerTemplate 從伺服器存下來的模板，檔案放在 MinecraftTemplatePath/<Key>
+type MinecraftServerTemplate struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Key         string    `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	OwnerID     uint      `gorm:"index;not null" json:"owner_id"`
+	Name        string    `gorm:"size:100;not null" json:"name"`
+	Description string    `gorm:"size:500" json:"description"`
+	ServerType  string    `gorm:"size:16;not null" json:"server_type"`
+	ServerVer   string    `gorm:"size:32;not null" json:"server_ver"`
+	Path        string    `gorm:"size:255;not null" json:"-"`
+	Public      bool      `gorm:"not null;default:false" json:"public"` // 公開的模板所有人都能用
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func AddTemplate(t *MinecraftServerTemplate) error {
+	return DB.Create(t).Error
+}
+
+// GetVisibleTemplates 自己的加上公開的
+func GetVisibleTemplates(userID uint) ([]MinecraftServerTemplate, error) {
+	var templates []MinecraftServerTemplate
+	err := DB.Where("owner_id = ? OR public = ?", userID, true).Order("id desc").Find(&templates).Error
+	if err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func GetVisibleTemplate(userID uint, id uint) (*MinecraftServerTemplate, error) {
+	var t MinecraftServerTemplate
+	err := DB.Where("id = ? AND (owner_id = ? OR public = ?)", id, userID, true).First(&t).Error
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func GetTemplate(id uint) (*MinecraftServerTemplate, error) {
+	var t MinecraftServerTemplate
+	err := DB.Where("id = ?", id).First(&t).Error
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func RemoveTemplate(id uint) error {
+	return DB.Where("id = ?", id).Delete(&MinecraftServerTemplate{}).Error
+}
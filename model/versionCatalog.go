@@ -0,0 +1,64 @@
+// model/versionCatalog.go
+
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MinecraftVersion 版本清單的一筆，Kind 是 vanilla / fabric-game / fabric-loader / fabric-installer
+// Ordinal 照來源的順序，0 是最新的
+type MinecraftVersion struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"-"`
+	Kind       string    `gorm:"size:32;not null;uniqueIndex:idx_kind_version" json:"kind"`
+	Version    string    `gorm:"size:128;not null;uniqueIndex:idx_kind_version" json:"version"`
+	Type       string    `gorm:"size:32" json:"type,omitempty"` // vanilla 才有：release / snapshot / old_beta / old_alpha
+	Stable     bool      `gorm:"not null;default:false" json:"stable"`
+	Ordinal    int       `gorm:"not null;default:0" json:"-"`
+	ReleasedAt time.Time `json:"released_at,omitempty"`
+	MetaURL    string    `gorm:"size:512" json:"-"` // vanilla 版本詳細資料的位址
+	MetaSHA1   string    `gorm:"size:40" json:"-"`
+	ServerURL  string    `gorm:"size:512" json:"server_url,omitempty"`
+	ServerSHA1 string    `gorm:"size:40" json:"server_sha1,omitempty"`
+	ServerSize int64     `gorm:"not null;default:0" json:"server_size,omitempty"`
+	FetchedAt  time.Time `gorm:"not null" json:"fetched_at"`
+}
+
+func GetVersions(kind string) ([]MinecraftVersion, error) {
+	var versions []MinecraftVersion
+	err := DB.Where("kind = ?", kind).Order("ordinal").Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func GetVersion(kind, version string) (*MinecraftVersion, error) {
+	var v MinecraftVersion
+	err := DB.Where("kind = ? AND version = ?", kind, version).First(&v).Error
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// ReplaceVersions 整個 kind 換成新抓到的清單
+func ReplaceVersions(kind string, versions []MinecraftVersion) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("kind = ?", kind).Delete(&MinecraftVersion{}).Error; err != nil {
+			return err
+		}
+		if len(versions) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(versions, 200).Error
+	})
+}
+
+// SetVersionServer 記下解析出來的 server jar
+func SetVersionServer(kind, version, url, sha1 string, size int64) error {
+	return DB.Model(&MinecraftVersion{}).Where("kind = ? AND version = ?", kind, version).
+		Updates(map[string]any{"server_url": url, "server_sha1": sha1, "server_size": size}).Error
+}
@@ -15,7 +15,14 @@ import (
 )
 
 func SetAPIRouter(router *gin.Engine) {
-	pl := common.GetPortList(30000, 30050)
+	pl, err := common.ParsePortRanges(common.PortRanges)
+	if err != nil {
+		common.FatalLog("invalid MC_PORT_RANGES: " + err.Error())
+	}
+	service.LoadJavaRuntimes()
+	service.InitCgroups()
+	service.StartTrashPurger()
+	service.StartVersionCatalog()
 
 	mgr := service.NewServerManager(pl)
 	svc := service.NewServerService(mgr)
@@ -30,18 +37,76 @@ func SetAPIRouter(router *gin.Engine) {
 	{
 		mcapi.GET("/finfo", controller.GetAllFabricVersions)
 		mcapi.GET("/vinfo", controller.GetAllVanillaVersions)
+		mcapi.GET("/versions/:kind", controller.GetVersionCatalog)
+		mcapi.GET("/jvm-presets", controller.ListJvmPresets)
+		mcapi.GET("/property-schema", controller.GetPropertySchema)
 	}
 	amcapi := mcapi.Group("/a")
 	amcapi.Use(middleware.ValidateJWT())
 	{
 		amcapi.POST("/create", controller.CreateServer)
+		amcapi.POST("/delete/:server_id", c.DeleteServerById)
+		amcapi.GET("/trash", c.ListTrashedServers)
+		amcapi.POST("/restore/:server_id", c.RestoreServer)
+		amcapi.POST("/clone/:server_id", c.CloneServer)
+		amcapi.GET("/templates", c.ListTemplates)
+		amcapi.POST("/template/save/:server_id", c.SaveTemplate)
+		amcapi.POST("/template/create/:template_id", c.CreateFromTemplate)
+		amcapi.POST("/template/delete/:template_id", c.DeleteTemplate)
 		amcapi.GET("/backup/:server_id", c.Backup)
+		amcapi.POST("/backup/:server_id", c.Backup)
+		amcapi.GET("/backups/:server_id", c.ListBackups)
+		amcapi.GET("/backups/:server_id/download/:name", c.DownloadBackup)
+		amcapi.POST("/backups/:server_id/delete/:name", c.DeleteBackup)
+		amcapi.POST("/backups/:server_id/restore/:name", c.RestoreBackup)
+		amcapi.POST("/backups/:server_id/retention", c.SetBackupRetention)
+		amcapi.GET("/snapshots/:server_id", c.ListSnapshots)
+		amcapi.POST("/snapshots/:server_id", c.CreateSnapshot)
+		amcapi.POST("/snapshots/:server_id/verify", c.VerifySnapshots)
+		amcapi.POST("/snapshots/:server_id/prune", c.PruneSnapshots)
+		amcapi.POST("/snapshots/:server_id/restore/:snapshot_id", c.RestoreSnapshot)
+		amcapi.POST("/world/:server_id/import", c.ImportWorld)
+		amcapi.GET("/world/:server_id/export", c.ExportWorld)
+		amcapi.GET("/files/:server_id/list", c.ListFiles)
+		amcapi.GET("/files/:server_id/read", c.ReadFile)
+		amcapi.POST("/files/:server_id/write", c.WriteFile)
+		amcapi.POST("/files/:server_id/upload", c.UploadFile)
+		amcapi.GET("/files/:server_id/download", c.DownloadFile)
+		amcapi.POST("/files/:server_id/rename", c.RenameFile)
+		amcapi.POST("/files/:server_id/delete", c.DeleteFile)
+		amcapi.POST("/files/:server_id/mkdir", c.MakeDir)
 		amcapi.POST("/status/:server_id", c.GetStatus)
 		amcapi.POST("/stop/:server_id", c.Stop)
 		amcapi.POST("/start/:server_id", c.Start)
 		amcapi.POST("/property/:server_id", c.GetServerProperties)
 		amcapi.POST("/UploadProperty/:server_id", c.UploadProperty)
+		amcapi.GET("/properties/:server_id", c.GetTypedProperties)
+		amcapi.PATCH("/properties/:server_id", c.PatchProperties)
+		amcapi.GET("/properties/:server_id/history", c.ListPropertyHistory)
+		amcapi.GET("/properties/:server_id/history/:revision_id", c.GetPropertyRevision)
+		amcapi.GET("/properties/:server_id/diff", c.DiffPropertyRevisions)
+		amcapi.POST("/properties/:server_id/rollback/:revision_id", c.RollbackProperties)
 		amcapi.POST("/cmd/:server_id", c.SendCommand)
+		amcapi.GET("/players/:server_id/:list", c.ListPlayerList)
+		amcapi.POST("/players/:server_id/:list/add", c.AddPlayerListEntry)
+		amcapi.POST("/players/:server_id/:list/remove", c.RemovePlayerListEntry)
+		amcapi.GET("/mods/:server_id", c.ListMods)
+		amcapi.GET("/mods/:server_id/search", c.SearchMods)
+		amcapi.POST("/mods/:server_id/install", c.InstallMod)
+		amcapi.POST("/mods/:server_id/update", c.UpdateMods)
+		amcapi.POST("/mods/:server_id/remove", c.RemoveMod)
+		amcapi.GET("/metrics/:server_id", c.GetMetrics)
+		amcapi.GET("/limits/:server_id", c.GetResourceLimits)
+		amcapi.POST("/limits/:server_id", c.UpdateResourceLimits)
+		amcapi.GET("/admin/ports", c.ListPorts)
+		amcapi.POST("/admin/ports/reserve", c.ReservePort)
+		amcapi.POST("/admin/ports/release", c.ReleasePort)
+		amcapi.GET("/jvm/:server_id", c.GetJvmSettings)
+		amcapi.POST("/jvm/:server_id", c.UpdateJvmSettings)
+		amcapi.GET("/java/:server_id", c.GetJavaRuntime)
+		amcapi.POST("/java/:server_id", c.SetJavaRuntime)
+		amcapi.GET("/restart-policy/:server_id", c.GetRestartPolicy)
+		amcapi.POST("/restart-policy/:server_id", c.SetRestartPolicy)
 	}
 	sapi := router.Group("/server-api")
 	sapi.Use(gzip.Gzip(gzip.DefaultCompression),
@@ -52,6 +117,9 @@ func SetAPIRouter(router *gin.Engine) {
 	asapi.Use(middleware.ValidateJWT())
 	{
 		asapi.GET("/log/:server_id", c.GetServerLog)
+		asapi.GET("/log/:server_id/files", c.ListServerLogFiles)
+		asapi.GET("/log/:server_id/history", c.GetServerLogHistory)
+		asapi.GET("/console/:server_id", c.StreamServerLog)
 	}
 
 	testApi := router.Group("/test-api")
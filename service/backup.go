@@ -0,0 +1,521 @@
+// service/backup.go
+// 世界備份：壓成 zip 放在 <workDir>/backups，旁邊一個同名 .json 的 manifest
+// 每台伺服器可以設定保留規則 (最近 N 份 / 每天一份 / 每週一份)
+
+package service
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const backupDirName = "backups"
+
+var ErrBackupNotFound = errors.New("backup not found")
+var ErrBackupInProgress = errors.New("another backup or restore is in progress")
+var ErrBackupCorrupted = errors.New("backup checksum mismatch")
+var ErrInvalidRetention = errors.New("invalid backup retention")
+
+var backupNameRe = regexp.MustCompile(`^backup-\d{8}-\d{6}(-\d+)?$`)
+
+// BackupManifest 跟 zip 放在一起的描述檔
+type BackupManifest struct {
+	Name        string    `json:"name"`
+	CreatedAt   time.Time `json:"created_at"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	Files       int       `json:"files"`
+	World       string    `json:"world"`
+	MCVersion   string    `json:"mc_version"`
+	ServerType  string    `json:"server_type"`
+	Source      string    `json:"source"` // manual / scheduled / hot ...
+	ServerID    string    `json:"server_id"`
+	ArchiveType string    `json:"archive_type"`
+	Online      bool      `json:"online"` // 伺服器執行中做的備份
+}
+
+// BackupRetention 0 代表不用這條規則；三條規則任一條要保留就保留
+type BackupRetention struct {
+	KeepLast   int `json:"keep_last"`
+	KeepDaily  int `json:"keep_daily"`
+	KeepWeekly int `json:"keep_weekly"`
+}
+
+// BackupOptions 建立備份時的附帶資訊
+type BackupOptions struct {
+	MCVersion  string
+	ServerType string
+	Source     string
+	Retention  BackupRetention
+}
+
+func ValidateBackupRetention(r BackupRetention) error {
+	if r.KeepLast < 0 || r.KeepDaily < 0 || r.KeepWeekly < 0 {
+		return fmt.Errorf("%w: values must not be negative", ErrInvalidRetention)
+	}
+	if r.KeepLast > 1000 || r.KeepDaily > 1000 || r.KeepWeekly > 1000 {
+		return fmt.Errorf("%w: values must not exceed 1000", ErrInvalidRetention)
+	}
+	if r.KeepLast == 0 && r.KeepDaily == 0 && r.KeepWeekly == 0 {
+		return fmt.Errorf("%w: at least one rule is required", ErrInvalidRetention)
+	}
+	return nil
+}
+
+// 同一台伺服器同時只能有一個備份或還原
+var backupLocks keyLocks
+
+func lockBackup(sid string) (func(), error) {
+	unlock, ok := backupLocks.tryLock(sid)
+	if !ok {
+		return nil, ErrBackupInProgress
+	}
+	return unlock, nil
+}
+
+func backupDir(workDir string) string {
+	return filepath.Join(workDir, backupDirName)
+}
+
+// backupPaths 名稱不合法就回傳 ErrBackupNotFound，避免被拿來讀其他檔案
+func backupPaths(workDir, name string) (string, string, error) {
+	if !backupNameRe.MatchString(name) {
+		return "", "", ErrBackupNotFound
+	}
+	dir := backupDir(workDir)
+	return filepath.Join(dir, name+".zip"), filepath.Join(dir, name+".json"), nil
+}
+
+// worldDirs 世界資料夾 (含 Bukkit 風格分開存的地獄/終界)，只回傳存在的
+func worldDirs(workDir string) []string {
+	world := levelName(workDir)
+	var dirs []string
+	for _, name := range []string{world, world + "_nether", world + "_the_end"} {
+		if info, err := os.Stat(filepath.Join(workDir, name)); err == nil && info.IsDir() {
+			dirs = append(dirs, name)
+		}
+	}
+	return dirs
+}
+
+// writeWorldArchive 把 srcDir 底下的世界資料夾壓進 dst，回傳檔案數
+func writeWorldArchive(srcDir string, dirs []string, dst io.Writer) (int, error) {
+	zw := zip.NewWriter(dst)
+	files := 0
+	for _, dir := range dirs {
+		root := filepath.Join(srcDir, dir)
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !d.Type().IsRegular() || d.Name() == "session.lock" {
+				return nil
+			}
+			rel, err := filepath.Rel(srcDir, path)
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			hdr, err := zip.FileInfoHeader(info)
+			if err != nil {
+				return err
+			}
+			hdr.Name = filepath.ToSlash(rel)
+			hdr.Method = zip.Deflate
+			w, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(w, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			files++
+			return nil
+		})
+		if err != nil {
+			zw.Close()
+			return files, err
+		}
+	}
+	return files, zw.Close()
+}
+
+// createBackupArchive 從 srcDir 壓縮世界到 workDir/backups，srcDir 通常就是 workDir
+func createBackupArchive(sid, workDir, srcDir string, opts BackupOptions) (*BackupManifest, error) {
+	dirs := worldDirs(srcDir)
+	if len(dirs) == 0 {
+		return nil, errors.New("world folder not found")
+	}
+	dir := backupDir(workDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	name := "backup-" + now.Format("20060102-150405")
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(dir, name+".zip")); os.IsNotExist(err) {
+			break
+		}
+		name = fmt.Sprintf("backup-%s-%d", now.Format("20060102-150405"), i)
+	}
+	archivePath, _, _ := backupPaths(workDir, name)
+
+	tmp := archivePath + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	files, err := writeWorldArchive(srcDir, dirs, io.MultiWriter(f, hash))
+	if err == nil {
+		err = f.Sync()
+	}
+	f.Close()
+	if err != nil {
+		_ = os.Remove(tmp)
+		return nil, err
+	}
+	if err := os.Rename(tmp, archivePath); err != nil {
+		_ = os.Remove(tmp)
+		return nil, err
+	}
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	source := opts.Source
+	if source == "" {
+		source = "manual"
+	}
+	manifest := &BackupManifest{
+		Name:        name,
+		CreatedAt:   now,
+		Size:        info.Size(),
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+		Files:       files,
+		World:       dirs[0],
+		MCVersion:   opts.MCVersion,
+		ServerType:  opts.ServerType,
+		Source:      source,
+		ServerID:    sid,
+		ArchiveType: "zip",
+	}
+	if err := writeManifest(workDir, manifest); err != nil {
+		_ = os.Remove(archivePath)
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func writeManifest(workDir string, m *BackupManifest) error {
+	_, manifestPath, err := backupPaths(workDir, m.Name)
+	if err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(m, "", "  ")
+	return os.WriteFile(manifestPath, data, 0644)
+}
+
+// ListBackups 新的在前
+func ListBackups(workDir string) ([]BackupManifest, error) {
+	entries, err := os.ReadDir(backupDir(workDir))
+	if os.IsNotExist(err) {
+		return []BackupManifest{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	backups := []BackupManifest{}
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".json")
+		if e.IsDir() || name == e.Name() || !backupNameRe.MatchString(name) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(backupDir(workDir), e.Name()))
+		if err != nil {
+			continue
+		}
+		var m BackupManifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			common.SysError(fmt.Sprintf("invalid backup manifest %s: %s", e.Name(), err.Error()))
+			continue
+		}
+		backups = append(backups, m)
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+func GetBackup(workDir, name string) (*BackupManifest, string, error) {
+	archivePath, manifestPath, err := backupPaths(workDir, name)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, "", ErrBackupNotFound
+	}
+	if _, err := os.Stat(archivePath); err != nil {
+		return nil, "", ErrBackupNotFound
+	}
+	var m BackupManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, "", err
+	}
+	return &m, archivePath, nil
+}
+
+// DeleteBackup 跟備份、還原用同一把鎖，不會刪掉正在讀的檔案
+func DeleteBackup(sid, workDir, name string) error {
+	unlock, err := lockBackup(sid)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return removeBackupFiles(workDir, name)
+}
+
+// removeBackupFiles 呼叫時必須持有 lockBackup
+func removeBackupFiles(workDir, name string) error {
+	archivePath, manifestPath, err := backupPaths(workDir, name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(manifestPath); err != nil {
+		return ErrBackupNotFound
+	}
+	if err := os.Remove(archivePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(manifestPath)
+}
+
+// selectExpiredBackups backups 要由新到舊排好
+func selectExpiredBackups(backups []BackupManifest, r BackupRetention) []BackupManifest {
+	keep := make(map[string]bool)
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	for i, b := range backups {
+		if i < r.KeepLast {
+			keep[b.Name] = true
+		}
+		day := b.CreatedAt.Format("2006-01-02")
+		if !days[day] && len(days) < r.KeepDaily {
+			days[day] = true
+			keep[b.Name] = true
+		}
+		y, w := b.CreatedAt.ISOWeek()
+		week := fmt.Sprintf("%d-%02d", y, w)
+		if !weeks[week] && len(weeks) < r.KeepWeekly {
+			weeks[week] = true
+			keep[b.Name] = true
+		}
+	}
+	var expired []BackupManifest
+	for _, b := range backups {
+		if !keep[b.Name] {
+			expired = append(expired, b)
+		}
+	}
+	return expired
+}
+
+// ApplyRetention 刪掉不符合保留規則的備份，規則全為 0 時不刪；呼叫時必須持有 lockBackup
+func ApplyRetention(workDir string, r BackupRetention) error {
+	if r.KeepLast == 0 && r.KeepDaily == 0 && r.KeepWeekly == 0 {
+		return nil
+	}
+	backups, err := ListBackups(workDir)
+	if err != nil {
+		return err
+	}
+	for _, b := range selectExpiredBackups(backups, r) {
+		if err := removeBackupFiles(workDir, b.Name); err != nil {
+			common.SysError(fmt.Sprintf("delete expired backup %s error: %s", b.Name, err.Error()))
+			continue
+		}
+		common.SysLog("Backup expired: " + b.Name)
+	}
+	return nil
+}
+
+func verifyArchive(path, sum string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return err
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != sum {
+		return ErrBackupCorrupted
+	}
+	return nil
+}
+
+// extractZip 解壓到 dst，擋掉 ../ 和絕對路徑
+func extractZip(archivePath, dst string) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	root := filepath.Clean(dst) + string(os.PathSeparator)
+	for _, zf := range zr.File {
+		target := filepath.Join(dst, filepath.FromSlash(zf.Name))
+		if !strings.HasPrefix(target, root) {
+			return fmt.Errorf("illegal path in archive: %s", zf.Name)
+		}
+		if zf.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			rc.Close()
+			return err
+		}
+		_, err = io.Copy(out, rc)
+		rc.Close()
+		out.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceWorlds 用 srcDir 裡的世界資料夾取代 workDir 的，舊的先改名，成功後才刪
+func replaceWorlds(srcDir, workDir string) error {
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		return err
+	}
+	suffix := ".old-" + time.Now().Format("20060102150405")
+	var moved []string
+	rollback := func() {
+		for _, name := range moved {
+			_ = os.RemoveAll(filepath.Join(workDir, name))
+			_ = os.Rename(filepath.Join(workDir, name+suffix), filepath.Join(workDir, name))
+		}
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		cur := filepath.Join(workDir, e.Name())
+		if _, err := os.Stat(cur); err == nil {
+			if err := os.Rename(cur, cur+suffix); err != nil {
+				rollback()
+				return err
+			}
+		}
+		moved = append(moved, e.Name())
+		if err := os.Rename(filepath.Join(srcDir, e.Name()), cur); err != nil {
+			rollback()
+			return err
+		}
+	}
+	for _, name := range moved {
+		_ = os.RemoveAll(filepath.Join(workDir, name+suffix))
+	}
+	return nil
+}
+
+// restoreBackupArchive 先驗 checksum、解壓到暫存目錄，全部成功才換掉世界
+func restoreBackupArchive(workDir, name string) error {
+	m, archivePath, err := GetBackup(workDir, name)
+	if err != nil {
+		return err
+	}
+	if err := verifyArchive(archivePath, m.SHA256); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(workDir, ".restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if err := extractZip(archivePath, tmp); err != nil {
+		return err
+	}
+	return replaceWorlds(tmp, workDir)
+}
+
+// BackUp 壓縮世界並套用保留規則，伺服器在跑的話做線上備份
+func (sm *ServerManager) BackUp(sid, workDir string, opts BackupOptions) (*BackupManifest, error) {
+	unlock, err := lockBackup(sid)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	sm.mu.RLock()
+	srv, exists := sm.servers[sid]
+	sm.mu.RUnlock()
+
+	var manifest *BackupManifest
+	if exists && srv.IsAlive() {
+		manifest, err = sm.hotBackup(srv, sid, workDir, opts)
+	} else {
+		manifest, err = createBackupArchive(sid, workDir, workDir, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := ApplyRetention(workDir, opts.Retention); err != nil {
+		common.SysError("apply backup retention error: " + err.Error())
+	}
+	return manifest, nil
+}
+
+// RestoreBackup 伺服器必須先停止
+func (sm *ServerManager) RestoreBackup(sid, workDir, name string) error {
+	if sm.isAlive(sid) {
+		return ErrServerRunning
+	}
+	unlock, err := lockBackup(sid)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return restoreBackupArchive(workDir, name)
+}
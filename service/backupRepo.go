@@ -0,0 +1,540 @@
+// service/backupRepo.go
+// 去重的增量備份庫：檔案切成固定大小的 chunk，以 SHA-256 當檔名存一次，snapshot 只記錄每個檔案由哪些 chunk 組成
+// region 檔是 4KB sector 對齊、原地改寫的，固定大小切塊就能讓沒變的部分共用同一個 blob
+//
+// <workDir>/backups/repo/blobs/<前兩碼>/<sha256>
+// <workDir>/backups/repo/snapshots/<id>.json
+
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const repoChunkSize = 1 << 20 // 1MB
+
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+var snapshotIDRe = regexp.MustCompile(`^snap-\d{8}-\d{6}(-\d+)?$`)
+var blobNameRe = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+type SnapshotFile struct {
+	Path    string      `json:"path"` // 相對於世界來源目錄，用 /
+	Size    int64       `json:"size"`
+	Mode    fs.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mod_time"`
+	Chunks  []string    `json:"chunks"`
+}
+
+// Snapshot 一次備份的 manifest
+type Snapshot struct {
+	ID        string         `json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	MCVersion string         `json:"mc_version"`
+	Source    string         `json:"source"`
+	Online    bool           `json:"online"`
+	TotalSize int64          `json:"total_size"` // 還原後的大小
+	AddedSize int64          `json:"added_size"` // 這次新增到 repo 的 blob 大小
+	Files     []SnapshotFile `json:"files,omitempty"`
+}
+
+// RepoVerifyReport Verify 的結果
+type RepoVerifyReport struct {
+	Snapshots int      `json:"snapshots"`
+	Blobs     int      `json:"blobs"`
+	Missing   []string `json:"missing"`
+	Corrupted []string `json:"corrupted"`
+}
+
+// RepoPruneReport Prune 的結果
+type RepoPruneReport struct {
+	Snapshots  []string `json:"snapshots"` // 刪掉的 snapshot
+	Blobs      int      `json:"blobs"`     // 刪掉的 blob 數
+	FreedBytes int64    `json:"freed_bytes"`
+}
+
+type backupRepo struct {
+	root string
+}
+
+func openBackupRepo(workDir string) (*backupRepo, error) {
+	r := &backupRepo{root: filepath.Join(backupDir(workDir), "repo")}
+	for _, dir := range []string{r.blobDir(), r.snapshotDir()} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (r *backupRepo) blobDir() string     { return filepath.Join(r.root, "blobs") }
+func (r *backupRepo) snapshotDir() string { return filepath.Join(r.root, "snapshots") }
+
+func (r *backupRepo) blobPath(sum string) string {
+	return filepath.Join(r.blobDir(), sum[:2], sum)
+}
+
+func (r *backupRepo) snapshotPath(id string) (string, error) {
+	if !snapshotIDRe.MatchString(id) {
+		return "", ErrSnapshotNotFound
+	}
+	return filepath.Join(r.snapshotDir(), id+".json"), nil
+}
+
+// putBlob 已經有同樣內容就不寫，回傳實際寫入的大小
+func (r *backupRepo) putBlob(data []byte) (string, int64, error) {
+	h := sha256.Sum256(data)
+	sum := hex.EncodeToString(h[:])
+	path := r.blobPath(sum)
+	if _, err := os.Stat(path); err == nil {
+		return sum, 0, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", 0, err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return "", 0, err
+	}
+	return sum, int64(len(data)), nil
+}
+
+// readBlob 讀出來順便驗證 hash
+func (r *backupRepo) readBlob(sum string) ([]byte, error) {
+	if !blobNameRe.MatchString(sum) {
+		return nil, fmt.Errorf("invalid blob name %q", sum)
+	}
+	data, err := os.ReadFile(r.blobPath(sum))
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.Sum256(data)
+	if hex.EncodeToString(h[:]) != sum {
+		return nil, fmt.Errorf("%w: blob %s", ErrBackupCorrupted, sum)
+	}
+	return data, nil
+}
+
+func (r *backupRepo) loadSnapshot(id string) (*Snapshot, error) {
+	path, err := r.snapshotPath(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+func (r *backupRepo) saveSnapshot(snap *Snapshot) error {
+	path, err := r.snapshotPath(snap.ID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// listSnapshots 新的在前，不含檔案清單
+func (r *backupRepo) listSnapshots(withFiles bool) ([]Snapshot, error) {
+	entries, err := os.ReadDir(r.snapshotDir())
+	if err != nil {
+		return nil, err
+	}
+	snaps := []Snapshot{}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		snap, err := r.loadSnapshot(e.Name()[:len(e.Name())-len(".json")])
+		if err != nil {
+			common.SysError(fmt.Sprintf("invalid snapshot %s: %s", e.Name(), err.Error()))
+			continue
+		}
+		if !withFiles {
+			snap.Files = nil
+		}
+		snaps = append(snaps, *snap)
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].CreatedAt.After(snaps[j].CreatedAt) })
+	return snaps, nil
+}
+
+// storeFile 切 chunk 寫進 repo；大小和修改時間跟上一份一樣就直接沿用，不用重讀
+func (r *backupRepo) storeFile(path string, info fs.FileInfo, prev *SnapshotFile) ([]string, int64, error) {
+	if prev != nil && prev.Size == info.Size() && prev.ModTime.Equal(info.ModTime()) && r.hasBlobs(prev.Chunks) {
+		return prev.Chunks, 0, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	chunks := []string{}
+	var added int64
+	buf := make([]byte, repoChunkSize)
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n > 0 {
+			sum, written, perr := r.putBlob(buf[:n])
+			if perr != nil {
+				return nil, 0, perr
+			}
+			chunks = append(chunks, sum)
+			added += written
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return chunks, added, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+}
+
+func (r *backupRepo) hasBlobs(sums []string) bool {
+	for _, sum := range sums {
+		if _, err := os.Stat(r.blobPath(sum)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// snapshot 把 srcDir 裡的世界資料夾存成一個新的 snapshot
+func (r *backupRepo) snapshot(srcDir string, opts BackupOptions) (*Snapshot, error) {
+	dirs := worldDirs(srcDir)
+	if len(dirs) == 0 {
+		return nil, errors.New("world folder not found")
+	}
+
+	// 上一份 snapshot 的檔案資訊，用來跳過沒變的檔案
+	prevFiles := map[string]*SnapshotFile{}
+	if snaps, err := r.listSnapshots(false); err == nil && len(snaps) > 0 {
+		if prev, err := r.loadSnapshot(snaps[0].ID); err == nil {
+			for i := range prev.Files {
+				prevFiles[prev.Files[i].Path] = &prev.Files[i]
+			}
+		}
+	}
+
+	now := time.Now()
+	id := "snap-" + now.Format("20060102-150405")
+	for i := 2; ; i++ {
+		path, _ := r.snapshotPath(id)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		id = fmt.Sprintf("snap-%s-%d", now.Format("20060102-150405"), i)
+	}
+	snap := &Snapshot{ID: id, CreatedAt: now, MCVersion: opts.MCVersion, Source: opts.Source}
+	if snap.Source == "" {
+		snap.Source = "manual"
+	}
+
+	for _, dir := range dirs {
+		err := filepath.WalkDir(filepath.Join(srcDir, dir), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !d.Type().IsRegular() || d.Name() == "session.lock" {
+				return nil
+			}
+			rel, err := filepath.Rel(srcDir, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			chunks, added, err := r.storeFile(path, info, prevFiles[rel])
+			if err != nil {
+				return fmt.Errorf("store %s: %w", rel, err)
+			}
+			snap.Files = append(snap.Files, SnapshotFile{
+				Path:    rel,
+				Size:    info.Size(),
+				Mode:    info.Mode().Perm(),
+				ModTime: info.ModTime(),
+				Chunks:  chunks,
+			})
+			snap.TotalSize += info.Size()
+			snap.AddedSize += added
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := r.saveSnapshot(snap); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// restore 重組檔案到 dst，每個 chunk 都會驗證 hash
+func (r *backupRepo) restore(id, dst string) error {
+	snap, err := r.loadSnapshot(id)
+	if err != nil {
+		return err
+	}
+	root := filepath.Clean(dst) + string(os.PathSeparator)
+	for _, file := range snap.Files {
+		target := filepath.Join(dst, filepath.FromSlash(file.Path))
+		if len(target) <= len(root) || target[:len(root)] != root {
+			return fmt.Errorf("illegal path in snapshot: %s", file.Path)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, file.Mode|0600)
+		if err != nil {
+			return err
+		}
+		for _, sum := range file.Chunks {
+			data, err := r.readBlob(sum)
+			if err == nil {
+				_, err = out.Write(data)
+			}
+			if err != nil {
+				out.Close()
+				return fmt.Errorf("restore %s: %w", file.Path, err)
+			}
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+		_ = os.Chtimes(target, file.ModTime, file.ModTime)
+	}
+	return nil
+}
+
+// referencedBlobs 所有 snapshot 用到的 blob
+func (r *backupRepo) referencedBlobs() (map[string]bool, int, error) {
+	snaps, err := r.listSnapshots(true)
+	if err != nil {
+		return nil, 0, err
+	}
+	refs := map[string]bool{}
+	for _, snap := range snaps {
+		for _, file := range snap.Files {
+			for _, sum := range file.Chunks {
+				refs[sum] = true
+			}
+		}
+	}
+	return refs, len(snaps), nil
+}
+
+// verify 檢查每個被引用的 blob 都存在且內容跟 hash 相符
+func (r *backupRepo) verify() (*RepoVerifyReport, error) {
+	refs, count, err := r.referencedBlobs()
+	if err != nil {
+		return nil, err
+	}
+	report := &RepoVerifyReport{Snapshots: count, Blobs: len(refs), Missing: []string{}, Corrupted: []string{}}
+	for sum := range refs {
+		if _, err := r.readBlob(sum); err != nil {
+			if os.IsNotExist(err) {
+				report.Missing = append(report.Missing, sum)
+			} else {
+				report.Corrupted = append(report.Corrupted, sum)
+			}
+		}
+	}
+	sort.Strings(report.Missing)
+	sort.Strings(report.Corrupted)
+	return report, nil
+}
+
+// prune 依保留規則刪 snapshot，再清掉沒有任何 snapshot 用到的 blob
+func (r *backupRepo) prune(retention BackupRetention) (*RepoPruneReport, error) {
+	report := &RepoPruneReport{Snapshots: []string{}}
+	if retention.KeepLast > 0 || retention.KeepDaily > 0 || retention.KeepWeekly > 0 {
+		snaps, err := r.listSnapshots(false)
+		if err != nil {
+			return nil, err
+		}
+		// 保留規則跟 zip 備份共用
+		items := make([]BackupManifest, len(snaps))
+		for i, s := range snaps {
+			items[i] = BackupManifest{Name: s.ID, CreatedAt: s.CreatedAt}
+		}
+		for _, expired := range selectExpiredBackups(items, retention) {
+			path, _ := r.snapshotPath(expired.Name)
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+			report.Snapshots = append(report.Snapshots, expired.Name)
+		}
+	}
+
+	refs, _, err := r.referencedBlobs()
+	if err != nil {
+		return nil, err
+	}
+	err = filepath.WalkDir(r.blobDir(), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		name := d.Name()
+		// 寫到一半的 .tmp 也一起清掉
+		if blobNameRe.MatchString(name) && refs[name] {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		report.Blobs++
+		report.FreedBytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// ---------------- ServerManager ----------------
+
+// SnapshotWorld 存一份增量 snapshot，伺服器在跑的話先讓它存檔再複製
+func (sm *ServerManager) SnapshotWorld(sid, workDir string, opts BackupOptions) (*Snapshot, error) {
+	unlock, err := lockBackup(sid)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	repo, err := openBackupRepo(workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	sm.mu.RLock()
+	srv, exists := sm.servers[sid]
+	sm.mu.RUnlock()
+
+	if !exists || !srv.IsAlive() {
+		snap, err := repo.snapshot(workDir, opts)
+		if err != nil {
+			return nil, err
+		}
+		snap.Files = nil
+		return snap, nil
+	}
+
+	snapDir, err := os.MkdirTemp(backupDir(workDir), ".snapshot-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(snapDir)
+	if err := srv.hotSnapshot(snapDir); err != nil {
+		return nil, err
+	}
+	snap, err := repo.snapshot(snapDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	snap.Online = true
+	if err := repo.saveSnapshot(snap); err != nil {
+		return nil, err
+	}
+	snap.Files = nil
+	return snap, nil
+}
+
+func ListSnapshots(workDir string) ([]Snapshot, error) {
+	repo, err := openBackupRepo(workDir)
+	if err != nil {
+		return nil, err
+	}
+	return repo.listSnapshots(false)
+}
+
+func (sm *ServerManager) VerifyBackupRepo(sid, workDir string) (*RepoVerifyReport, error) {
+	unlock, err := lockBackup(sid)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	repo, err := openBackupRepo(workDir)
+	if err != nil {
+		return nil, err
+	}
+	return repo.verify()
+}
+
+func (sm *ServerManager) PruneBackupRepo(sid, workDir string, retention BackupRetention) (*RepoPruneReport, error) {
+	unlock, err := lockBackup(sid)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	repo, err := openBackupRepo(workDir)
+	if err != nil {
+		return nil, err
+	}
+	return repo.prune(retention)
+}
+
+// RestoreSnapshot 伺服器必須先停止；先完整重組到暫存目錄，成功才換掉世界
+func (sm *ServerManager) RestoreSnapshot(sid, workDir, id string) error {
+	if sm.isAlive(sid) {
+		return ErrServerRunning
+	}
+	unlock, err := lockBackup(sid)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	repo, err := openBackupRepo(workDir)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(workDir, ".restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if err := repo.restore(id, tmp); err != nil {
+		return err
+	}
+	return replaceWorlds(tmp, workDir)
+}
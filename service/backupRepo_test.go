@@ -0,0 +1,182 @@
+package service
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func randomBytes(seed int64, n int) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(b)
+	return b
+}
+
+// assertSameFiles dst 裡的世界檔案要跟 want 一模一樣
+func assertSameFiles(t *testing.T, dst string, want map[string][]byte) {
+	t.Helper()
+	for rel, data := range want {
+		got, err := os.ReadFile(filepath.Join(dst, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatalf("%s: %v", rel, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("%s differs after restore", rel)
+		}
+	}
+}
+
+func TestBackupRepoDedupPruneRestore(t *testing.T) {
+	workDir := t.TempDir()
+	files := map[string][]byte{
+		"world/level.dat":          []byte("level"),
+		"world/region/r.0.0.mca":   randomBytes(1, repoChunkSize*2+repoChunkSize/2),
+		"world/region/r.0.1.mca":   randomBytes(2, 4096),
+		"world_nether/DIM-1/r.mca": randomBytes(3, repoChunkSize+1),
+		"world/empty.dat":          {},
+	}
+	for rel, data := range files {
+		mustWrite(t, filepath.Join(workDir, filepath.FromSlash(rel)), string(data))
+	}
+	mustWrite(t, filepath.Join(workDir, "world", "session.lock"), "x")
+
+	repo, err := openBackupRepo(workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := repo.snapshot(workDir, BackupOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.AddedSize == 0 || first.TotalSize != first.AddedSize {
+		t.Fatalf("first snapshot: total %d, added %d", first.TotalSize, first.AddedSize)
+	}
+	for _, f := range first.Files {
+		if f.Path == "world/session.lock" {
+			t.Fatal("session.lock should not be stored")
+		}
+	}
+
+	second, err := repo.snapshot(workDir, BackupOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.AddedSize != 0 {
+		t.Fatalf("unchanged world added %d bytes", second.AddedSize)
+	}
+
+	// 修改時間變了但內容一樣，blob 還是共用
+	later := time.Now().Add(time.Hour)
+	for rel := range files {
+		_ = os.Chtimes(filepath.Join(workDir, filepath.FromSlash(rel)), later, later)
+	}
+	touched, err := repo.snapshot(workDir, BackupOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if touched.AddedSize != 0 {
+		t.Fatalf("touched world added %d bytes", touched.AddedSize)
+	}
+
+	// 只改第二個 chunk，只會多一個 blob
+	region := filepath.Join(workDir, "world", "region", "r.0.0.mca")
+	changed := append([]byte{}, files["world/region/r.0.0.mca"]...)
+	changed[repoChunkSize+10] ^= 0xff
+	if err := os.WriteFile(region, changed, 0644); err != nil {
+		t.Fatal(err)
+	}
+	third, err := repo.snapshot(workDir, BackupOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.AddedSize != repoChunkSize {
+		t.Fatalf("one changed chunk added %d bytes, want %d", third.AddedSize, repoChunkSize)
+	}
+
+	dst := t.TempDir()
+	if err := repo.restore(first.ID, dst); err != nil {
+		t.Fatal(err)
+	}
+	assertSameFiles(t, dst, files)
+
+	// 只留最新一份，舊的 chunk 要被清掉，最新一份用到的要留著
+	report, err := repo.prune(BackupRetention{KeepLast: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Snapshots) != 3 || report.Blobs != 1 || report.FreedBytes != repoChunkSize {
+		t.Fatalf("prune report %+v", report)
+	}
+	verify, err := repo.verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verify.Snapshots != 1 || len(verify.Missing) != 0 || len(verify.Corrupted) != 0 {
+		t.Fatalf("verify report %+v", verify)
+	}
+
+	dst = t.TempDir()
+	if err := repo.restore(third.ID, dst); err != nil {
+		t.Fatal(err)
+	}
+	files["world/region/r.0.0.mca"] = changed
+	assertSameFiles(t, dst, files)
+	if _, err := repo.loadSnapshot(first.ID); err != ErrSnapshotNotFound {
+		t.Fatalf("pruned snapshot still loads: %v", err)
+	}
+}
+
+func TestBackupRepoDetectsCorruptedBlob(t *testing.T) {
+	workDir := t.TempDir()
+	mustWrite(t, filepath.Join(workDir, "world", "level.dat"), "level")
+	repo, err := openBackupRepo(workDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap, err := repo.snapshot(workDir, BackupOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := snap.Files[0].Chunks[0]
+	if err := os.WriteFile(repo.blobPath(sum), []byte("broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	report, err := repo.verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Corrupted) != 1 || report.Corrupted[0] != sum {
+		t.Fatalf("verify report %+v", report)
+	}
+	if err := repo.restore(snap.ID, t.TempDir()); err == nil {
+		t.Fatal("restore should fail on a corrupted blob")
+	}
+}
+
+// 線上快照要保留修改時間，下一次 snapshot 才能跳過沒變的檔案
+func TestSnapshotEntryKeepsModTime(t *testing.T) {
+	src := t.TempDir()
+	mustWrite(t, filepath.Join(src, "world", "region", "r.0.0.mca"), "region")
+	old := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(src, "world", "region", "r.0.0.mca"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := t.TempDir()
+	if err := snapshotEntry(filepath.Join(src, "world"), filepath.Join(dst, "world")); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(dst, "world", "region", "r.0.0.mca"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(old) {
+		t.Fatalf("mod time = %s, want %s", info.ModTime(), old)
+	}
+	if err := snapshotEntry(filepath.Join(src, "missing"), filepath.Join(dst, "missing")); err != nil {
+		t.Fatalf("missing source should be skipped: %v", err)
+	}
+}
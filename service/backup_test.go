@@ -0,0 +1,52 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSelectExpiredBackups(t *testing.T) {
+	// 由新到舊；10-12 是週一，跟 10-18 同一個 ISO 週
+	times := []string{
+		"2026-10-18T12:00:00Z", // b0
+		"2026-10-18T08:00:00Z", // b1
+		"2026-10-17T12:00:00Z", // b2
+		"2026-10-16T12:00:00Z", // b3
+		"2026-10-12T12:00:00Z", // b4
+		"2026-10-11T12:00:00Z", // b5
+		"2026-10-04T12:00:00Z", // b6
+		"2026-09-27T12:00:00Z", // b7
+	}
+	backups := make([]BackupManifest, len(times))
+	for i, s := range times {
+		at, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		backups[i] = BackupManifest{Name: "b" + string(rune('0'+i)), CreatedAt: at}
+	}
+
+	tests := []struct {
+		name    string
+		r       BackupRetention
+		expired []string
+	}{
+		{"keep last", BackupRetention{KeepLast: 2}, []string{"b2", "b3", "b4", "b5", "b6", "b7"}},
+		{"keep daily", BackupRetention{KeepDaily: 3}, []string{"b1", "b4", "b5", "b6", "b7"}},
+		{"keep weekly", BackupRetention{KeepWeekly: 2}, []string{"b1", "b2", "b3", "b4", "b6", "b7"}},
+		{"combined", BackupRetention{KeepLast: 1, KeepDaily: 2, KeepWeekly: 3}, []string{"b1", "b3", "b4", "b7"}},
+		{"keep more than exist", BackupRetention{KeepLast: 20}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, b := range selectExpiredBackups(backups, tt.r) {
+				got = append(got, b.Name)
+			}
+			if !reflect.DeepEqual(got, tt.expired) {
+				t.Fatalf("expired = %v, want %v", got, tt.expired)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+// service/cgroup.go
+// 每台伺服器放進自己的 cgroup v2，限制 CPU / 記憶體 / process 數，避免一台吃光整台主機
+// 沒有 cgroup v2 (或沒有權限) 時維持原本直接開 child process 的做法
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	cgroupFSRoot    = "/sys/fs/cgroup"
+	cgroupCPUPeriod = 100000 // us
+)
+
+var cgroupControllers = []string{"cpu", "memory", "pids"}
+
+var ErrInvalidResourceLimits = errors.New("invalid resource limits")
+
+var (
+	cgroupParent string // 空的代表 cgroup 不可用
+	cgroupMu     sync.RWMutex
+)
+
+// ResourceLimits cgroup 的限制，0 代表不限制
+type ResourceLimits struct {
+	CPUWeight int   `json:"cpu_weight"` // 1-10000，預設 100
+	CPUQuota  int   `json:"cpu_quota"`  // 百分比，100 = 一個核心
+	MemoryMax int64 `json:"memory_max"` // bytes
+	PidsMax   int   `json:"pids_max"`
+}
+
+// CgroupUsage 從 cgroup 檔案讀回來的用量
+type CgroupUsage struct {
+	MemoryCurrent int64   `json:"memory_current"`
+	MemoryMax     int64   `json:"memory_max"`    // 0 = max
+	CPUUsage      float64 `json:"cpu_usage"`     // 累計秒數
+	CPUThrottled  float64 `json:"cpu_throttled"` // 被 quota 擋住的累計秒數
+	PidsCurrent   int     `json:"pids_current"`
+	OOMKills      int     `json:"oom_kills"`
+}
+
+// ResourceLimitCaps 角色可以設定的上限，0 代表可以設成不限制
+type ResourceLimitCaps struct {
+	CPUWeight int `json:"cpu_weight"`
+	CPUQuota  int `json:"cpu_quota"`
+	PidsMax   int `json:"pids_max"`
+}
+
+// ResourceLimitsForRole 跟 MaxMemoryForRole 一樣依角色決定；admin 以上沒有額外限制
+func ResourceLimitsForRole(role int) ResourceLimitCaps {
+	if role >= common.RoleAdminUser {
+		return ResourceLimitCaps{CPUWeight: 10000}
+	}
+	return ResourceLimitCaps{
+		CPUWeight: common.MaxCPUWeightCommonUser,
+		CPUQuota:  common.MaxCPUQuotaCommonUser,
+		PidsMax:   common.MaxPidsCommonUser,
+	}
+}
+
+// ValidateResourceLimits 一般使用者不能設成不限制，也不能超過角色的上限
+func ValidateResourceLimits(role, cpuWeight, cpuQuota, pidsMax int) error {
+	caps := ResourceLimitsForRole(role)
+	if cpuWeight < 1 || cpuWeight > caps.CPUWeight {
+		return fmt.Errorf("%w: cpu weight must be between 1 and %d", ErrInvalidResourceLimits, caps.CPUWeight)
+	}
+	if cpuQuota < 0 || cpuQuota > 100*runtime.NumCPU() {
+		return fmt.Errorf("%w: cpu quota must be between 0 and %d", ErrInvalidResourceLimits, 100*runtime.NumCPU())
+	}
+	if caps.CPUQuota > 0 && (cpuQuota == 0 || cpuQuota > caps.CPUQuota) {
+		return fmt.Errorf("%w: cpu quota must be between 1 and %d", ErrInvalidResourceLimits, caps.CPUQuota)
+	}
+	if pidsMax != 0 && (pidsMax < 64 || pidsMax > 65536) {
+		return fmt.Errorf("%w: pids max must be 0 or between 64 and 65536", ErrInvalidResourceLimits)
+	}
+	if caps.PidsMax > 0 && (pidsMax == 0 || pidsMax > caps.PidsMax) {
+		return fmt.Errorf("%w: pids max must be between 64 and %d", ErrInvalidResourceLimits, caps.PidsMax)
+	}
+	return nil
+}
+
+// ClampResourceLimits 啟動時套用角色上限，預設值或舊資料的「不限制」也會被壓到上限
+func ClampResourceLimits(role int, limits ResourceLimits) ResourceLimits {
+	caps := ResourceLimitsForRole(role)
+	if limits.CPUWeight > caps.CPUWeight {
+		limits.CPUWeight = caps.CPUWeight
+	}
+	if caps.CPUQuota > 0 && (limits.CPUQuota == 0 || limits.CPUQuota > caps.CPUQuota) {
+		limits.CPUQuota = caps.CPUQuota
+	}
+	if caps.PidsMax > 0 && (limits.PidsMax == 0 || limits.PidsMax > caps.PidsMax) {
+		limits.PidsMax = caps.PidsMax
+	}
+	return limits
+}
+
+// MemoryLimitFor heap 之外還要留給 metaspace、thread stack、native memory
+func MemoryLimitFor(maxMemoryMB int) int64 {
+	return int64(maxMemoryMB+common.CgroupMemoryOverhead) * 1024 * 1024
+}
+
+// InitCgroups 建立 CgroupParent 並打開 cpu/memory/pids controller，失敗就停用 cgroup
+func InitCgroups() {
+	parent, err := setupCgroupParent(common.CgroupParent)
+	cgroupMu.Lock()
+	cgroupParent = parent
+	cgroupMu.Unlock()
+	if err != nil {
+		common.SysError("WARNING: cgroup v2 unavailable, servers run without resource limits: " + err.Error())
+		return
+	}
+	common.SysLog("cgroup v2 enabled: " + parent)
+}
+
+func setupCgroupParent(parent string) (string, error) {
+	if parent == "" {
+		return "", errors.New("CGROUP_PARENT is empty")
+	}
+	if _, err := os.Stat(filepath.Join(cgroupFSRoot, "cgroup.controllers")); err != nil {
+		return "", errors.New("cgroup v2 is not mounted at " + cgroupFSRoot)
+	}
+	parent = filepath.Clean(parent)
+	if !strings.HasPrefix(parent, cgroupFSRoot+"/") {
+		return "", fmt.Errorf("%s is not under %s", parent, cgroupFSRoot)
+	}
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return "", err
+	}
+	// 上一層和自己都要把 controller 開給子 cgroup
+	enable := "+" + strings.Join(cgroupControllers, " +")
+	_ = os.WriteFile(filepath.Join(filepath.Dir(parent), "cgroup.subtree_control"), []byte(enable), 0644)
+	if err := os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte(enable), 0644); err != nil {
+		return "", err
+	}
+	enabled, err := os.ReadFile(filepath.Join(parent, "cgroup.subtree_control"))
+	if err != nil {
+		return "", err
+	}
+	for _, c := range cgroupControllers {
+		if !strings.Contains(" "+strings.TrimSpace(string(enabled))+" ", " "+c+" ") {
+			return "", fmt.Errorf("controller %s cannot be enabled", c)
+		}
+	}
+	return parent, nil
+}
+
+func CgroupsEnabled() bool {
+	cgroupMu.RLock()
+	defer cgroupMu.RUnlock()
+	return cgroupParent != ""
+}
+
+// serverCgroupPath cgroup 不可用時回傳空字串
+func serverCgroupPath(sid string) string {
+	cgroupMu.RLock()
+	defer cgroupMu.RUnlock()
+	if cgroupParent == "" {
+		return ""
+	}
+	return filepath.Join(cgroupParent, sid)
+}
+
+// createCgroup 建立 (或重用) 伺服器的 cgroup 並寫入限制
+func createCgroup(sid string, limits ResourceLimits) (string, error) {
+	path := serverCgroupPath(sid)
+	if path == "" {
+		return "", nil
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return "", err
+	}
+
+	weight := limits.CPUWeight
+	if weight <= 0 {
+		weight = 100
+	}
+	cpuMax := "max " + strconv.Itoa(cgroupCPUPeriod)
+	if limits.CPUQuota > 0 {
+		cpuMax = fmt.Sprintf("%d %d", limits.CPUQuota*cgroupCPUPeriod/100, cgroupCPUPeriod)
+	}
+	memMax := "max"
+	if limits.MemoryMax > 0 {
+		memMax = strconv.FormatInt(limits.MemoryMax, 10)
+	}
+	pidsMax := "max"
+	if limits.PidsMax > 0 {
+		pidsMax = strconv.Itoa(limits.PidsMax)
+	}
+
+	files := [][2]string{
+		{"cpu.weight", strconv.Itoa(weight)},
+		{"cpu.max", cpuMax},
+		{"memory.max", memMax},
+		{"pids.max", pidsMax},
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(path, f[0]), []byte(f[1]), 0644); err != nil {
+			return "", fmt.Errorf("write %s: %w", f[0], err)
+		}
+	}
+	return path, nil
+}
+
+// addToCgroup java 剛啟動還沒有 fork 其他 process，之後產生的 child 會自動留在同一個 cgroup
+func addToCgroup(path string, pid int) error {
+	return os.WriteFile(filepath.Join(path, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644)
+}
+
+// removeCgroup process 都結束後才刪得掉，kernel 可能晚一點才把 process 移出，稍微重試
+func removeCgroup(path string) {
+	if path == "" {
+		return
+	}
+	var err error
+	for i := 0; i < 5; i++ {
+		if err = os.Remove(path); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	common.SysError("remove cgroup error: " + err.Error())
+}
+
+func readCgroupInt(path, file string) (int64, error) {
+	data, err := os.ReadFile(filepath.Join(path, file))
+	if err != nil {
+		return 0, err
+	}
+	v := strings.TrimSpace(string(data))
+	if v == "max" {
+		return 0, nil
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+// readCgroupKeyed 讀 cpu.stat / memory.events 這種 "key value" 一行一筆的檔案
+func readCgroupKeyed(path, file string) (map[string]int64, error) {
+	data, err := os.ReadFile(filepath.Join(path, file))
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]int64{}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			out[fields[0]] = v
+		}
+	}
+	return out, nil
+}
+
+func readCgroupUsage(path string) (*CgroupUsage, error) {
+	var usage CgroupUsage
+	var err error
+	if usage.MemoryCurrent, err = readCgroupInt(path, "memory.current"); err != nil {
+		return nil, err
+	}
+	usage.MemoryMax, _ = readCgroupInt(path, "memory.max")
+	pids, _ := readCgroupInt(path, "pids.current")
+	usage.PidsCurrent = int(pids)
+	if stat, err := readCgroupKeyed(path, "cpu.stat"); err == nil {
+		usage.CPUUsage = float64(stat["usage_usec"]) / 1e6
+		usage.CPUThrottled = float64(stat["throttled_usec"]) / 1e6
+	}
+	if events, err := readCgroupKeyed(path, "memory.events"); err == nil {
+		usage.OOMKills = int(events["oom_kill"])
+	}
+	return &usage, nil
+}
+
+// CgroupUsage 伺服器沒有在 cgroup 裡時回傳 nil
+func (s *Server) CgroupUsage() *CgroupUsage {
+	s.mu.RLock()
+	path := s.cgroup
+	s.mu.RUnlock()
+	if path == "" {
+		return nil
+	}
+	usage, err := readCgroupUsage(path)
+	if err != nil {
+		return nil
+	}
+	return usage
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"errors"
+	"go-backend/common"
+	"testing"
+)
+
+func TestValidateResourceLimits(t *testing.T) {
+	common.MaxCPUWeightCommonUser = 200
+	common.MaxCPUQuotaCommonUser = 100
+	common.MaxPidsCommonUser = 2048
+
+	tests := []struct {
+		name                  string
+		role                  int
+		weight, quota, pidMax int
+		ok                    bool
+	}{
+		{"within caps", common.RoleCommonUser, 100, 100, 1024, true},
+		{"weight over cap", common.RoleCommonUser, 10000, 100, 1024, false},
+		{"unlimited quota", common.RoleCommonUser, 100, 0, 1024, false},
+		{"quota over cap", common.RoleCommonUser, 100, 200, 1024, false},
+		{"unlimited pids", common.RoleCommonUser, 100, 100, 0, false},
+		{"pids over cap", common.RoleCommonUser, 100, 100, 4096, false},
+		{"admin unlimited", common.RoleAdminUser, 10000, 0, 0, true},
+		{"admin weight range", common.RoleAdminUser, 10001, 0, 0, false},
+		{"pids too small", common.RoleAdminUser, 100, 0, 10, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateResourceLimits(tt.role, tt.weight, tt.quota, tt.pidMax)
+			if tt.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidResourceLimits) {
+				t.Fatalf("expected ErrInvalidResourceLimits, got %v", err)
+			}
+		})
+	}
+}
+
+func TestClampResourceLimits(t *testing.T) {
+	common.MaxCPUWeightCommonUser = 200
+	common.MaxCPUQuotaCommonUser = 100
+	common.MaxPidsCommonUser = 2048
+
+	got := ClampResourceLimits(common.RoleCommonUser, ResourceLimits{CPUWeight: 10000, CPUQuota: 0, PidsMax: 0, MemoryMax: 1 << 30})
+	want := ResourceLimits{CPUWeight: 200, CPUQuota: 100, PidsMax: 2048, MemoryMax: 1 << 30}
+	if got != want {
+		t.Fatalf("common user: got %+v, want %+v", got, want)
+	}
+	admin := ResourceLimits{CPUWeight: 10000, CPUQuota: 0, PidsMax: 0}
+	if got := ClampResourceLimits(common.RoleAdminUser, admin); got != admin {
+		t.Fatalf("admin: got %+v, want %+v", got, admin)
+	}
+}
@@ -0,0 +1,168 @@
+// service/console.go
+// 把 console 輸出即時推給訂閱者 (SSE 用)
+
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"go-backend/common"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 每個訂閱者的緩衝行數，塞滿代表對方太慢，直接斷線讓他重連拿 backlog
+const consoleSubscriberBuffer = 256
+
+type consoleHub struct {
+	mu   sync.Mutex
+	subs map[chan string]struct{}
+}
+
+func newConsoleHub() *consoleHub {
+	return &consoleHub{subs: make(map[chan string]struct{})}
+}
+
+func (h *consoleHub) publish(line string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- line:
+		default:
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (h *consoleHub) subscribe() (chan string, func()) {
+	ch := make(chan string, consoleSubscriberBuffer)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+
+	cancel := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subs[ch]; ok {
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// appendLog 寫入 buffer 和推送要在同一把鎖裡，訂閱時的 backlog 才不會漏行或重複
+func (s *Server) appendLog(line string) {
+	s.logMu.Lock()
+	s.logBuffer.WriteString(line)
+	if s.logFile != nil {
+		s.logFile.WriteLine(time.Now(), line)
+	}
+	s.console.publish(strings.TrimRight(line, "\r\n"))
+	s.logMu.Unlock()
+
+	// 不能在 logMu 裡拿 s.mu，Start 是反過來的順序
+	if serverReadyRe.MatchString(line) {
+		s.markReady()
+	}
+}
+
+// openLogFile 呼叫時必須持有 s.logMu，開不了檔就只留在記憶體
+func (s *Server) openLogFile() {
+	if s.logFile != nil {
+		s.logFile.Close()
+	}
+	l, err := openConsoleLog(s.workDir)
+	if err != nil {
+		common.SysError(fmt.Sprintf("server %s open console log error: %s", s.sid, err.Error()))
+		s.logFile = nil
+		return
+	}
+	s.logFile = l
+}
+
+// SubscribeConsole 回傳目前的 backlog 以及之後每一行 console 輸出
+func (s *Server) SubscribeConsole() (string, <-chan string, func()) {
+	s.logMu.Lock()
+	defer s.logMu.Unlock()
+	backlog := s.ReadLatestLog()
+	ch, cancel := s.console.subscribe()
+	return backlog, ch, cancel
+}
+
+func (s *Server) captureLogs(stdout io.Reader, done chan struct{}) {
+	defer close(done)
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			s.appendLog(line)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// followLogFile 重新接管的伺服器沒有 stdout，改成追 logs/latest.log
+func (s *Server) followLogFile(exited chan struct{}) {
+	path := filepath.Join(s.workDir, "logs", "latest.log")
+	var offset int64
+	if info, err := os.Stat(path); err == nil {
+		offset = info.Size() - 1024*8
+		if offset < 0 {
+			offset = 0
+		}
+	}
+
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+	var partial string
+	for {
+		offset, partial = s.readLogFileFrom(path, offset, partial)
+		select {
+		case <-exited:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) readLogFileFrom(path string, offset int64, partial string) (int64, string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return offset, partial
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return offset, partial
+	}
+	if info.Size() < offset {
+		// latest.log 被輪替了，從頭讀
+		offset = 0
+		partial = ""
+	}
+	if _, err := f.Seek(offset, 0); err != nil {
+		return offset, partial
+	}
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadString('\n')
+		offset += int64(len(line))
+		if err != nil {
+			// 還沒寫完的一行留到下次
+			return offset, partial + line
+		}
+		s.appendLog(partial + line)
+		partial = ""
+	}
+}
@@ -0,0 +1,289 @@
+// service/consoleLog.go
+// console 輸出另外寫一份到 <workDir>/console-logs，依大小輪替，並提供歷史查詢
+
+package service
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	consoleLogDir        = "console-logs"
+	consoleLogActive     = "console.log"
+	consoleLogTimeLayout = "20060102-150405"
+)
+
+var ErrInvalidLogFile = errors.New("invalid log file name")
+
+var consoleLogNameRe = regexp.MustCompile(`^console(-\d{8}-\d{6}(-\d+)?)?\.log$`)
+
+type ConsoleLogFileInfo struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	Start   time.Time `json:"start"`
+	ModTime time.Time `json:"mod_time"`
+	Active  bool      `json:"active"`
+}
+
+type ConsoleLogLine struct {
+	Time time.Time `json:"time"`
+	Text string    `json:"text"`
+}
+
+type ConsoleLogPage struct {
+	File       string           `json:"file"`
+	Offset     int64            `json:"offset"`
+	NextOffset int64            `json:"next_offset"`
+	EOF        bool             `json:"eof"`
+	Lines      []ConsoleLogLine `json:"lines"`
+}
+
+// consoleLogFile 每行格式：<RFC3339Nano 時間> <原始內容>
+type consoleLogFile struct {
+	mu   sync.Mutex
+	dir  string
+	f    *os.File
+	size int64
+}
+
+// openConsoleLog 每次開機都從新檔開始，上一次的 console.log 會被輪替掉
+func openConsoleLog(workDir string) (*consoleLogFile, error) {
+	dir := filepath.Join(workDir, consoleLogDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	l := &consoleLogFile{dir: dir}
+	if err := l.rotate(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *consoleLogFile) WriteLine(t time.Time, line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return
+	}
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+	n, err := io.WriteString(l.f, t.Format(time.RFC3339Nano)+" "+line)
+	l.size += int64(n)
+	if err != nil {
+		common.SysError("write console log error: " + err.Error())
+		return
+	}
+	if l.size >= int64(common.ConsoleLogMaxSize) {
+		if err := l.rotate(); err != nil {
+			common.SysError("rotate console log error: " + err.Error())
+		}
+	}
+}
+
+func (l *consoleLogFile) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f != nil {
+		_ = l.f.Close()
+		l.f = nil
+	}
+}
+
+// rotate 呼叫時必須持有 l.mu (或還沒有人拿到 l)
+func (l *consoleLogFile) rotate() error {
+	if l.f != nil {
+		_ = l.f.Close()
+		l.f = nil
+	}
+
+	active := filepath.Join(l.dir, consoleLogActive)
+	if info, err := os.Stat(active); err == nil && info.Size() > 0 {
+		start := consoleLogStartTime(active, info.ModTime())
+		name := "console-" + start.Format(consoleLogTimeLayout)
+		dst := filepath.Join(l.dir, name+".log")
+		for i := 1; ; i++ {
+			if _, err := os.Stat(dst); os.IsNotExist(err) {
+				break
+			}
+			dst = filepath.Join(l.dir, fmt.Sprintf("%s-%d.log", name, i))
+		}
+		if err := os.Rename(active, dst); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(active, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	l.f = f
+	l.size = 0
+	l.prune()
+	return nil
+}
+
+// prune 只保留最新的 ConsoleLogKeep 個已輪替檔案
+func (l *consoleLogFile) prune() {
+	files, err := ListConsoleLogs(filepath.Dir(l.dir))
+	if err != nil {
+		return
+	}
+	var rotated []ConsoleLogFileInfo
+	for _, f := range files {
+		if !f.Active {
+			rotated = append(rotated, f)
+		}
+	}
+	for len(rotated) > common.ConsoleLogKeep {
+		_ = os.Remove(filepath.Join(l.dir, rotated[0].Name))
+		rotated = rotated[1:]
+	}
+}
+
+func parseConsoleLogLine(raw string) ConsoleLogLine {
+	parts := strings.SplitN(raw, " ", 2)
+	if len(parts) == 2 {
+		if t, err := time.Parse(time.RFC3339Nano, parts[0]); err == nil {
+			return ConsoleLogLine{Time: t, Text: parts[1]}
+		}
+	}
+	return ConsoleLogLine{Text: raw}
+}
+
+// consoleLogStartTime 用第一行的時間當作檔案開始時間
+func consoleLogStartTime(path string, fallback time.Time) time.Time {
+	f, err := os.Open(path)
+	if err != nil {
+		return fallback
+	}
+	defer f.Close()
+	line, _ := bufio.NewReader(f).ReadString('\n')
+	parsed := parseConsoleLogLine(strings.TrimRight(line, "\r\n"))
+	if parsed.Time.IsZero() {
+		return fallback
+	}
+	return parsed.Time
+}
+
+// ListConsoleLogs 依時間由舊到新列出，console.log 永遠在最後
+func ListConsoleLogs(workDir string) ([]ConsoleLogFileInfo, error) {
+	dir := filepath.Join(workDir, consoleLogDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []ConsoleLogFileInfo{}, nil
+		}
+		return nil, err
+	}
+
+	files := make([]ConsoleLogFileInfo, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || !consoleLogNameRe.MatchString(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		active := e.Name() == consoleLogActive
+		files = append(files, ConsoleLogFileInfo{
+			Name:    e.Name(),
+			Size:    info.Size(),
+			Start:   consoleLogStartTime(filepath.Join(dir, e.Name()), info.ModTime()),
+			ModTime: info.ModTime(),
+			Active:  active,
+		})
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].Active != files[j].Active {
+			return !files[i].Active
+		}
+		if !files[i].Start.Equal(files[j].Start) {
+			return files[i].Start.Before(files[j].Start)
+		}
+		return files[i].ModTime.Before(files[j].ModTime)
+	})
+	return files, nil
+}
+
+// ReadConsoleLogPage 從 offset 開始讀最多 limit bytes，只回傳完整的行
+func ReadConsoleLogPage(workDir, name string, offset int64, limit int) (*ConsoleLogPage, error) {
+	if !consoleLogNameRe.MatchString(name) {
+		return nil, ErrInvalidLogFile
+	}
+	f, err := os.Open(filepath.Join(workDir, consoleLogDir, name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if offset < 0 {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	page := &ConsoleLogPage{File: name, Offset: offset, Lines: []ConsoleLogLine{}}
+	reader := bufio.NewReader(f)
+	consumed := 0
+	for consumed < limit {
+		raw, err := reader.ReadString('\n')
+		if err != nil {
+			// 最後一行可能還在寫，下次再讀
+			page.EOF = true
+			break
+		}
+		consumed += len(raw)
+		page.Lines = append(page.Lines, parseConsoleLogLine(strings.TrimRight(raw, "\r\n")))
+	}
+	page.NextOffset = offset + int64(consumed)
+	return page, nil
+}
+
+// ReadConsoleLogRange 回傳 [from, to] 之間的行，超過 limit 時 truncated 為 true
+func ReadConsoleLogRange(workDir string, from, to time.Time, limit int) ([]ConsoleLogLine, bool, error) {
+	files, err := ListConsoleLogs(workDir)
+	if err != nil {
+		return nil, false, err
+	}
+
+	lines := []ConsoleLogLine{}
+	for _, info := range files {
+		if info.ModTime.Before(from) || info.Start.After(to) {
+			continue
+		}
+		f, err := os.Open(filepath.Join(workDir, consoleLogDir, info.Name))
+		if err != nil {
+			return nil, false, err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := parseConsoleLogLine(scanner.Text())
+			if line.Time.Before(from) || line.Time.After(to) {
+				continue
+			}
+			if len(lines) >= limit {
+				f.Close()
+				return lines, true, nil
+			}
+			lines = append(lines, line)
+		}
+		f.Close()
+	}
+	return lines, false, nil
+}
@@ -0,0 +1,379 @@
+// service/fileManager.go
+// 伺服器目錄的檔案管理，所有路徑都限制在伺服器目錄 (SystemPath) 裡面，symlink 也要解開檢查
+
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrPathEscape    = errors.New("path is outside of the server directory")
+	ErrFileNotFound  = errors.New("file not found")
+	ErrFileExists    = errors.New("file already exists")
+	ErrFileTooLarge  = errors.New("file too large")
+	ErrNotTextFile   = errors.New("not a text file")
+	ErrIsDirectory   = errors.New("path is a directory")
+	ErrFileProtected = errors.New("file is in use while the server is running")
+	ErrFileReadOnly  = errors.New("file is managed by the panel and is read-only")
+)
+
+// 伺服器開著的時候會讀寫或鎖住的檔案，世界資料夾另外判斷
+var protectedWhileRunning = map[string]bool{
+	"server.jar":          true,
+	"eula.txt":            true,
+	"ops.json":            true,
+	"whitelist.json":      true,
+	"banned-players.json": true,
+	"banned-ips.json":     true,
+	"usercache.json":      true,
+	"mods":                true,
+	"libraries":           true,
+	"versions":            true,
+	".fabric":             true,
+}
+
+// 面板自己管理的目錄和檔案，只能透過對應的 API 修改
+// server.properties 要經過設定 API 才會檢查 schema、記錄修改歷史
+var panelManaged = map[string]bool{
+	backupDirName:       true,
+	consoleLogDir:       true,
+	"server.properties": true,
+}
+
+// FileEntry 目錄列表的一筆
+type FileEntry struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"` // 相對於伺服器目錄，用 /
+	IsDir   bool      `json:"is_dir"`
+	Symlink bool      `json:"symlink"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+type serverPath struct {
+	root string // 解開 symlink 後的伺服器目錄
+	abs  string
+	rel  string // 相對於 root，用 /，根目錄是 "."
+}
+
+func within(root, path string) bool {
+	return path == root || strings.HasPrefix(path, root+string(os.PathSeparator))
+}
+
+// realPath 解開路徑上已經存在的部分，不存在的部分原樣接回去
+func realPath(path string) (string, error) {
+	real, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return real, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path, nil
+	}
+	realParent, err := realPath(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(realParent, filepath.Base(path)), nil
+}
+
+// resolveServerPath 把使用者給的路徑轉成伺服器目錄裡的絕對路徑
+// follow=false 時最後一段如果是 symlink 不解開 (刪除、改名只動 link 本身)
+func resolveServerPath(workDir, rel string, follow bool) (*serverPath, error) {
+	root, err := filepath.Abs(workDir)
+	if err == nil {
+		root, err = filepath.EvalSymlinks(root)
+	}
+	if err != nil {
+		return nil, err
+	}
+	clean := filepath.Clean(string(os.PathSeparator) + filepath.FromSlash(strings.ReplaceAll(rel, "\\", "/")))
+	if clean == string(os.PathSeparator) {
+		return &serverPath{root: root, abs: root, rel: "."}, nil
+	}
+
+	parent, err := realPath(filepath.Join(root, filepath.Dir(clean)))
+	if err != nil {
+		return nil, err
+	}
+	abs := filepath.Join(parent, filepath.Base(clean))
+	if follow {
+		if info, err := os.Lstat(abs); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			// 指向不存在的目標也不行，不然寫入會在外面建立檔案
+			if abs, err = filepath.EvalSymlinks(abs); err != nil {
+				return nil, ErrPathEscape
+			}
+		}
+	}
+	if !within(root, parent) || !within(root, abs) || abs == root {
+		return nil, ErrPathEscape
+	}
+	r, _ := filepath.Rel(root, abs)
+	return &serverPath{root: root, abs: abs, rel: filepath.ToSlash(r)}, nil
+}
+
+// checkWritable 面板管理的目錄和檔案一律不能改；伺服器開著的時候不能動它正在用的檔案
+func checkWritable(p *serverPath, running bool) error {
+	if p.rel == "." {
+		return ErrPathEscape
+	}
+	top := strings.SplitN(p.rel, "/", 2)[0]
+	if panelManaged[top] {
+		return ErrFileReadOnly
+	}
+	if !running {
+		return nil
+	}
+	if protectedWhileRunning[top] {
+		return ErrFileProtected
+	}
+	level := levelName(p.root)
+	for _, world := range []string{level, level + "_nether", level + "_the_end"} {
+		if p.rel == world || strings.HasPrefix(p.rel, world+"/") {
+			return ErrFileProtected
+		}
+	}
+	return nil
+}
+
+func ListServerDir(workDir, rel string) ([]FileEntry, error) {
+	p, err := resolveServerPath(workDir, rel, true)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(p.abs)
+	if os.IsNotExist(err) {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	files := []FileEntry{}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		entry := FileEntry{
+			Name:    e.Name(),
+			Path:    strings.TrimPrefix(p.rel+"/"+e.Name(), "./"),
+			IsDir:   e.IsDir(),
+			Symlink: e.Type()&os.ModeSymlink != 0,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		}
+		// 只有指到伺服器目錄裡面的 link 才顯示目標的類型
+		if entry.Symlink {
+			if target, err := resolveServerPath(workDir, entry.Path, true); err == nil {
+				if st, err := os.Stat(target.abs); err == nil {
+					entry.IsDir, entry.Size = st.IsDir(), st.Size()
+				}
+			}
+		}
+		files = append(files, entry)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].IsDir != files[j].IsDir {
+			return files[i].IsDir
+		}
+		return files[i].Name < files[j].Name
+	})
+	return files, nil
+}
+
+// ReadServerTextFile 只讀得到 UTF-8 文字檔
+func ReadServerTextFile(workDir, rel string) (string, error) {
+	p, err := resolveServerPath(workDir, rel, true)
+	if err != nil {
+		return "", err
+	}
+	info, err := statRegular(p.abs)
+	if err != nil {
+		return "", err
+	}
+	if info.Size() > int64(common.FileTextMaxSize)<<10 {
+		return "", ErrFileTooLarge
+	}
+	data, err := os.ReadFile(p.abs)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		return "", ErrNotTextFile
+	}
+	return string(data), nil
+}
+
+// ResolveServerDownload 回傳可以直接送出的檔案路徑
+func ResolveServerDownload(workDir, rel string) (string, error) {
+	p, err := resolveServerPath(workDir, rel, true)
+	if err != nil {
+		return "", err
+	}
+	if _, err := statRegular(p.abs); err != nil {
+		return "", err
+	}
+	return p.abs, nil
+}
+
+func statRegular(path string) (os.FileInfo, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, ErrIsDirectory
+	}
+	if !info.Mode().IsRegular() {
+		return nil, ErrNotTextFile
+	}
+	return info, nil
+}
+
+// writeFileAtomic 先寫暫存檔再改名，寫到一半失敗不會留下壞掉的檔案
+func writeFileAtomic(path string, r io.Reader, limit int64) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		if info.IsDir() {
+			return ErrIsDirectory
+		}
+		mode = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, io.LimitReader(r, limit+1))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if n > limit {
+		return ErrFileTooLarge
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// WriteServerTextFile 不存在就建立
+func (sm *ServerManager) WriteServerTextFile(sid, workDir, rel, content string) error {
+	p, err := resolveServerPath(workDir, rel, true)
+	if err != nil {
+		return err
+	}
+	if err := checkWritable(p, sm.isAlive(sid)); err != nil {
+		return err
+	}
+	if !utf8.ValidString(content) {
+		return ErrNotTextFile
+	}
+	return writeFileAtomic(p.abs, strings.NewReader(content), int64(common.FileTextMaxSize)<<10)
+}
+
+// SaveServerUpload 上傳到 dir 底下，檔名只取最後一段
+func (sm *ServerManager) SaveServerUpload(sid, workDir, dir, filename string, overwrite bool, r io.Reader) (string, error) {
+	name := filepath.Base(filepath.Clean("/" + strings.ReplaceAll(filename, "\\", "/")))
+	if name == "/" || name == "." {
+		return "", fmt.Errorf("invalid file name %q", filename)
+	}
+	p, err := resolveServerPath(workDir, strings.TrimSuffix(dir, "/")+"/"+name, true)
+	if err != nil {
+		return "", err
+	}
+	if err := checkWritable(p, sm.isAlive(sid)); err != nil {
+		return "", err
+	}
+	if _, err := os.Lstat(p.abs); err == nil && !overwrite {
+		return "", ErrFileExists
+	}
+	if err := writeFileAtomic(p.abs, r, int64(common.FileUploadMaxSize)<<20); err != nil {
+		return "", err
+	}
+	return p.rel, nil
+}
+
+func (sm *ServerManager) RenameServerFile(sid, workDir, from, to string) error {
+	src, err := resolveServerPath(workDir, from, false)
+	if err != nil {
+		return err
+	}
+	dst, err := resolveServerPath(workDir, to, false)
+	if err != nil {
+		return err
+	}
+	running := sm.isAlive(sid)
+	if err := checkWritable(src, running); err != nil {
+		return err
+	}
+	if err := checkWritable(dst, running); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(src.abs); os.IsNotExist(err) {
+		return ErrFileNotFound
+	}
+	if _, err := os.Lstat(dst.abs); err == nil {
+		return ErrFileExists
+	}
+	// 不能把資料夾搬進自己底下
+	if within(src.abs, dst.abs) {
+		return ErrPathEscape
+	}
+	if err := os.MkdirAll(filepath.Dir(dst.abs), 0755); err != nil {
+		return err
+	}
+	return os.Rename(src.abs, dst.abs)
+}
+
+// DeleteServerFile 資料夾整個刪掉；symlink 只刪 link 本身
+func (sm *ServerManager) DeleteServerFile(sid, workDir, rel string) error {
+	p, err := resolveServerPath(workDir, rel, false)
+	if err != nil {
+		return err
+	}
+	if err := checkWritable(p, sm.isAlive(sid)); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(p.abs); os.IsNotExist(err) {
+		return ErrFileNotFound
+	}
+	return os.RemoveAll(p.abs)
+}
+
+func (sm *ServerManager) MakeServerDir(sid, workDir, rel string) error {
+	p, err := resolveServerPath(workDir, rel, true)
+	if err != nil {
+		return err
+	}
+	if err := checkWritable(p, sm.isAlive(sid)); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(p.abs); err == nil {
+		return ErrFileExists
+	}
+	return os.MkdirAll(p.abs, 0755)
+}
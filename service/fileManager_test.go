@@ -0,0 +1,116 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveServerPath(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "server")
+	outside := filepath.Join(base, "outside")
+	mustWrite(t, filepath.Join(root, "world", "level.dat"), "x")
+	mustWrite(t, filepath.Join(outside, "secret"), "x")
+	links := map[string]string{
+		"escape":      outside,
+		"escape-file": filepath.Join(outside, "secret"),
+		"dangling":    filepath.Join(outside, "missing"),
+		"inside":      filepath.Join(root, "world"),
+		"self":        root,
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		rel    string
+		follow bool
+		want   string // 空字串代表要回傳 ErrPathEscape
+	}{
+		{"root", "", true, "."},
+		{"root dot", ".", true, "."},
+		{"root slash", "/", true, "."},
+		{"parent of root", "..", true, "."},
+		{"plain file", "world/level.dat", true, "world/level.dat"},
+		{"missing file", "world/new.txt", true, "world/new.txt"},
+		{"dot dot stays inside", "../../etc/passwd", true, "etc/passwd"},
+		{"nested dot dot", "world/../../etc/passwd", true, "etc/passwd"},
+		{"absolute path", "/etc/passwd", true, "etc/passwd"},
+		{"backslashes", "world\\level.dat", true, "world/level.dat"},
+		{"backslash dot dot", "..\\..\\etc\\passwd", true, "etc/passwd"},
+		{"symlink outside", "escape", true, ""},
+		{"symlink outside no follow", "escape", false, "escape"},
+		{"through symlink dir", "escape/secret", false, ""},
+		{"through symlink dir new file", "escape/new.txt", true, ""},
+		{"symlink file outside", "escape-file", true, ""},
+		{"dangling symlink", "dangling", true, ""},
+		{"dangling symlink no follow", "dangling", false, "dangling"},
+		{"symlink inside", "inside", true, "world"},
+		{"through symlink inside", "inside/level.dat", true, "world/level.dat"},
+		{"symlink to root", "self", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := resolveServerPath(root, tt.rel, tt.follow)
+			if tt.want == "" {
+				if !errors.Is(err, ErrPathEscape) {
+					t.Fatalf("expected ErrPathEscape, got %v (%+v)", err, p)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if p.rel != tt.want {
+				t.Fatalf("rel = %q, want %q", p.rel, tt.want)
+			}
+			if !within(p.root, p.abs) {
+				t.Fatalf("abs %q is outside of %q", p.abs, p.root)
+			}
+		})
+	}
+}
+
+func TestCheckWritable(t *testing.T) {
+	root := t.TempDir()
+	mustWrite(t, filepath.Join(root, "server.properties"), "level-name=survival\n")
+
+	tests := []struct {
+		name    string
+		rel     string
+		running bool
+		want    error
+	}{
+		{"root", ".", false, ErrPathEscape},
+		{"backups", backupDirName + "/a.tar.gz", false, ErrFileReadOnly},
+		{"console logs", consoleLogDir + "/latest.log", false, ErrFileReadOnly},
+		{"backups while running", backupDirName, true, ErrFileReadOnly},
+		{"server.properties", "server.properties", false, ErrFileReadOnly},
+		{"jar stopped", "server.jar", false, nil},
+		{"jar running", "server.jar", true, ErrFileProtected},
+		{"mods running", "mods/a.jar", true, ErrFileProtected},
+		{"world running", "survival/level.dat", true, ErrFileProtected},
+		{"nether running", "survival_nether", true, ErrFileProtected},
+		{"end running", "survival_the_end/DIM1", true, ErrFileProtected},
+		{"world stopped", "survival/level.dat", false, nil},
+		{"similar name running", "survival_backup/level.dat", true, nil},
+		{"default world name not protected", "world/level.dat", true, nil},
+		{"config running", "config/paper.yml", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkWritable(&serverPath{root: root, abs: filepath.Join(root, filepath.FromSlash(tt.rel)), rel: tt.rel}, tt.running)
+			if tt.want == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
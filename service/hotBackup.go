@@ -0,0 +1,148 @@
+// service/hotBackup.go
+// 線上備份：save-off -> save-all flush -> 等 console 確認存檔完成 -> 複製世界 -> save-on
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+var ErrSaveTimeout = errors.New("timed out waiting for the server to save the world")
+
+// 1.13 之後是 "Saved the game"，更舊的版本是 "Saved the world"
+var worldSavedRe = regexp.MustCompile(`Saved the (game|world)`)
+
+// hotSnapshot 伺服器存檔完成後把世界複製到 snapDir，不論成功失敗都會送 save-on
+func (s *Server) hotSnapshot(snapDir string) error {
+	if s.State() != StateRunning {
+		return fmt.Errorf("%w: server is %s", ErrServerRunning, s.State())
+	}
+
+	// 先訂閱才不會漏掉存檔完成的那一行
+	_, lines, cancel := s.SubscribeConsole()
+	defer cancel()
+
+	if _, err := s.RunCommand("save-off"); err != nil {
+		return fmt.Errorf("save-off: %w", err)
+	}
+	defer func() {
+		if _, err := s.RunCommand("save-on"); err != nil {
+			common.SysError(fmt.Sprintf("server %s save-on error: %s", s.sid, err.Error()))
+		}
+	}()
+
+	// rcon 要等存檔完才會回應，所以逾時跟等 console 一樣用 HotBackupTimeout
+	timeout := time.Duration(common.HotBackupTimeout) * time.Second
+	res, err := s.runCommand("save-all flush", timeout)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrSaveTimeout
+	}
+	if err != nil {
+		return fmt.Errorf("save-all: %w", err)
+	}
+	// rcon 會等指令執行完才回傳，輸出裡就有結果；stdin 只能看 console
+	if !worldSavedRe.MatchString(res.Output) {
+		if err := waitForLine(lines, worldSavedRe, timeout); err != nil {
+			return err
+		}
+	}
+
+	s.mu.RLock()
+	workDir := s.workDir
+	s.mu.RUnlock()
+	entries := append(worldDirs(workDir), "server.properties")
+	for _, name := range entries {
+		if err := snapshotEntry(filepath.Join(workDir, name), filepath.Join(snapDir, name)); err != nil {
+			return fmt.Errorf("snapshot %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// snapshotEntry 跟 copyEntry 一樣但保留修改時間，增量備份才能靠大小 + 修改時間跳過沒變的檔案
+// 修改時間在複製前取得，複製途中被改寫的話下次比對會不一樣，會重新讀取
+func snapshotEntry(src, dst string) error {
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := common.CopyFile(path, target); err != nil {
+			return err
+		}
+		return os.Chtimes(target, info.ModTime(), info.ModTime())
+	})
+}
+
+func waitForLine(lines <-chan string, re *regexp.Regexp, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				// 伺服器關了或訂閱被踢掉
+				return errors.New("console closed while waiting for save")
+			}
+			if re.MatchString(line) {
+				return nil
+			}
+		case <-timer.C:
+			return ErrSaveTimeout
+		}
+	}
+}
+
+// hotBackup 快照放在 backups 底下的暫存目錄，壓縮完就刪
+func (sm *ServerManager) hotBackup(srv *Server, sid, workDir string, opts BackupOptions) (*BackupManifest, error) {
+	if err := os.MkdirAll(backupDir(workDir), 0755); err != nil {
+		return nil, err
+	}
+	snapDir, err := os.MkdirTemp(backupDir(workDir), ".snapshot-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(snapDir)
+
+	if err := srv.hotSnapshot(snapDir); err != nil {
+		return nil, err
+	}
+	manifest, err := createBackupArchive(sid, workDir, snapDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	manifest.Online = true
+	if err := writeManifest(workDir, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
@@ -0,0 +1,275 @@
+// service/jarCache.go
+// server jar 的共用快取：同一個類型+版本只下載一次，放在 MinecraftJarCachePath，建立伺服器時 hard link (不行就複製) 過去
+// 每次使用前都會重新驗證 hash，快取檔壞掉就刪掉重新下載
+
+package service
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	jarDownloadAttempts = 3
+	jarMaxSize          = 512 << 20
+)
+
+var ErrJarHashMismatch = errors.New("server jar hash mismatch")
+
+// 下載大檔不設整體逾時，只限制等回應的時間
+var jarHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: 30 * time.Second,
+	},
+}
+
+var jarKeyRe = regexp.MustCompile(`[^A-Za-z0-9._+-]`)
+
+// jarSpec SHA1 / SHA256 是版本清單提供的，沒有的話第一次下載時記下來，之後用來檢查快取檔
+type jarSpec struct {
+	Key    string
+	URL    string
+	SHA1   string
+	SHA256 string
+}
+
+// jarMeta 跟快取檔放在一起的 .json
+type jarMeta struct {
+	URL          string    `json:"url"`
+	SHA1         string    `json:"sha1"`
+	SHA256       string    `json:"sha256"`
+	Size         int64     `json:"size"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+}
+
+func vanillaJarSpec(version, url, sha1 string) jarSpec {
+	return jarSpec{Key: "vanilla-" + version, URL: url, SHA1: strings.ToLower(sha1)}
+}
+
+// fabricJarSpec Fabric meta 不提供 hash，只能靠第一次下載時記下的 hash 檢查快取檔
+func fabricJarSpec(version, loader, installer, url string) jarSpec {
+	return jarSpec{Key: fmt.Sprintf("fabric-%s-loader-%s-installer-%s", version, loader, installer), URL: url}
+}
+
+func jarCachePaths(key string) (jar, meta, part string) {
+	base := filepath.Join(common.MinecraftJarCachePath, jarKeyRe.ReplaceAllString(key, "_"))
+	return base + ".jar", base + ".json", base + ".jar.part"
+}
+
+// ---------------- 合併同時的請求 ----------------
+
+type jarCall struct {
+	done chan struct{}
+	path string
+	err  error
+}
+
+var (
+	jarCallsMu sync.Mutex
+	jarCalls   = map[string]*jarCall{}
+)
+
+// cachedServerJar 同一個 key 同時只會有一個下載，其他人等它的結果
+func cachedServerJar(spec jarSpec) (string, error) {
+	jarCallsMu.Lock()
+	if call, ok := jarCalls[spec.Key]; ok {
+		jarCallsMu.Unlock()
+		<-call.done
+		return call.path, call.err
+	}
+	call := &jarCall{done: make(chan struct{})}
+	jarCalls[spec.Key] = call
+	jarCallsMu.Unlock()
+
+	call.path, call.err = ensureCachedJar(spec)
+
+	jarCallsMu.Lock()
+	delete(jarCalls, spec.Key)
+	jarCallsMu.Unlock()
+	close(call.done)
+	return call.path, call.err
+}
+
+// ---------------- 驗證 ----------------
+
+func hashJar(path string) (string, string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", 0, err
+	}
+	defer f.Close()
+	h1, h256 := sha1.New(), sha256.New()
+	n, err := io.Copy(io.MultiWriter(h1, h256), f)
+	if err != nil {
+		return "", "", 0, err
+	}
+	return hex.EncodeToString(h1.Sum(nil)), hex.EncodeToString(h256.Sum(nil)), n, nil
+}
+
+// checkJarHashes 期望值是空的就不比
+func checkJarHashes(spec jarSpec, sum1, sum256 string) error {
+	if (spec.SHA1 != "" && spec.SHA1 != sum1) || (spec.SHA256 != "" && spec.SHA256 != sum256) {
+		return fmt.Errorf("%w: %s", ErrJarHashMismatch, spec.Key)
+	}
+	return nil
+}
+
+// verifyCachedJar 快取檔要跟 .json 記錄的 hash 一樣，也要符合版本清單目前給的 hash
+func verifyCachedJar(spec jarSpec, jarPath, metaPath string) error {
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		return err
+	}
+	var meta jarMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return err
+	}
+	sum1, sum256, size, err := hashJar(jarPath)
+	if err != nil {
+		return err
+	}
+	if sum1 != meta.SHA1 || sum256 != meta.SHA256 || size != meta.Size {
+		return fmt.Errorf("%w: cached %s is corrupted", ErrJarHashMismatch, spec.Key)
+	}
+	return checkJarHashes(spec, sum1, sum256)
+}
+
+// ---------------- 下載 ----------------
+
+// downloadJarPart 接著 .part 已經下載的部分繼續下載；伺服器不支援 Range 就從頭來
+func downloadJarPart(url, part string) error {
+	var offset int64
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", common.UserAgent())
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := jarHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("http get %s error: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusOK:
+		offset = 0
+		flags |= os.O_TRUNC
+	case offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// 已經下載完了 (或 .part 比檔案還大)，交給後面的驗證判斷
+		return nil
+	default:
+		return fmt.Errorf("bad status downloading %s: %s", url, resp.Status)
+	}
+
+	out, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, io.LimitReader(resp.Body, jarMaxSize-offset+1))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("writing to %s error: %w", part, err)
+	}
+	if info, err := os.Stat(part); err == nil && info.Size() > jarMaxSize {
+		return fmt.Errorf("download %s: %w", url, ErrFileTooLarge)
+	}
+	return nil
+}
+
+// finishJarPart .part 要是完整的 jar (zip) 而且 hash 對得上才改成正式檔名
+func finishJarPart(spec jarSpec, part, jarPath, metaPath string) error {
+	sum1, sum256, size, err := hashJar(part)
+	if err != nil {
+		return err
+	}
+	if err := checkJarHashes(spec, sum1, sum256); err != nil {
+		return err
+	}
+	zr, err := zip.OpenReader(part)
+	if err != nil {
+		return fmt.Errorf("%w: %s is not a valid jar", ErrJarHashMismatch, spec.Key)
+	}
+	zr.Close()
+	meta, err := json.MarshalIndent(jarMeta{URL: spec.URL, SHA1: sum1, SHA256: sum256, Size: size, DownloadedAt: time.Now()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	// 先放 jar 再寫 .json，中途失敗的話下次驗證會失敗重新下載
+	if err := os.Rename(part, jarPath); err != nil {
+		return err
+	}
+	return os.WriteFile(metaPath, meta, 0644)
+}
+
+func ensureCachedJar(spec jarSpec) (string, error) {
+	jarPath, metaPath, part := jarCachePaths(spec.Key)
+	if _, err := os.Stat(jarPath); err == nil {
+		err := verifyCachedJar(spec, jarPath, metaPath)
+		if err == nil {
+			return jarPath, nil
+		}
+		common.SysError(fmt.Sprintf("server jar cache %s is invalid, downloading again: %s", spec.Key, err.Error()))
+		_ = os.Remove(jarPath)
+		_ = os.Remove(metaPath)
+	}
+	if err := os.MkdirAll(common.MinecraftJarCachePath, 0755); err != nil {
+		return "", err
+	}
+
+	var err error
+	for attempt := 1; attempt <= jarDownloadAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(time.Duration(attempt-1) * 2 * time.Second)
+		}
+		if err = downloadJarPart(spec.URL, part); err != nil {
+			// 網路錯誤的話 .part 留著，下次從斷掉的地方接著下載
+			continue
+		}
+		if err = finishJarPart(spec, part, jarPath, metaPath); err == nil {
+			return jarPath, nil
+		}
+		// 內容不對就從頭下載
+		_ = os.Remove(part)
+	}
+	return "", fmt.Errorf("download %s after %d attempts: %w", spec.Key, jarDownloadAttempts, err)
+}
+
+// installServerJar 把快取的 jar 放到 dest，同一個磁碟用 hard link，不然就複製
+// 伺服器目錄裡的 server.jar 被換掉 (上傳、檔案管理) 都是寫新檔再改名，不會動到快取
+func installServerJar(spec jarSpec, dest string) error {
+	src, err := cachedServerJar(spec)
+	if err != nil {
+		return err
+	}
+	_ = os.Remove(dest)
+	if err := os.Link(src, dest); err == nil {
+		return nil
+	}
+	return common.CopyFile(src, dest)
+}
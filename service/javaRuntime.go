@@ -0,0 +1,230 @@
+// service/javaRuntime.go
+// 已安裝的 Java runtime 清單，以及 Minecraft 版本需要的 Java 主版本
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var ErrNoJavaRuntime = errors.New("no suitable java runtime installed")
+
+type JavaRuntime struct {
+	Major   int    `json:"major"`
+	Version string `json:"version"`
+	Path    string `json:"path"`
+}
+
+// javaRequirement 版本 <= UpTo 的 Minecraft 需要 Major ~ MaxMajor 的 Java，UpTo 為空代表其餘所有版本
+type javaRequirement struct {
+	UpTo     string
+	Major    int
+	MaxMajor int // 0 = 沒有上限
+}
+
+var (
+	javaRuntimes     []JavaRuntime
+	javaRequirements []javaRequirement
+	javaMu           sync.RWMutex
+)
+
+var javaVersionRe = regexp.MustCompile(`version "([^"]+)"`)
+
+// LoadJavaRuntimes 依 JAVA_RUNTIMES 逐一執行 -version 取得主版本，JAVA_VERSION_MAP 決定版本對應
+func LoadJavaRuntimes() {
+	var runtimes []JavaRuntime
+	seen := map[int]bool{}
+	for _, path := range common.JavaRuntimePaths {
+		rt, err := probeJavaRuntime(path)
+		if err != nil {
+			common.SysError(fmt.Sprintf("java runtime %s unusable: %s", path, err.Error()))
+			continue
+		}
+		if seen[rt.Major] {
+			// 同一個主版本只留第一個
+			continue
+		}
+		seen[rt.Major] = true
+		runtimes = append(runtimes, rt)
+		common.SysLog(fmt.Sprintf("Java %d (%s) registered: %s", rt.Major, rt.Version, rt.Path))
+	}
+	sort.Slice(runtimes, func(i, j int) bool { return runtimes[i].Major < runtimes[j].Major })
+
+	reqs, err := parseJavaVersionMap(common.JavaVersionMap)
+	if err != nil {
+		common.SysError("invalid JAVA_VERSION_MAP: " + err.Error())
+	}
+
+	javaMu.Lock()
+	javaRuntimes = runtimes
+	javaRequirements = reqs
+	javaMu.Unlock()
+
+	if len(runtimes) == 0 {
+		common.SysError("no java runtime found, minecraft servers will not be able to start")
+	}
+}
+
+func probeJavaRuntime(path string) (JavaRuntime, error) {
+	// java -version 會把版本印在 stderr
+	out, err := exec.Command(path, "-version").CombinedOutput()
+	if err != nil {
+		return JavaRuntime{}, err
+	}
+	m := javaVersionRe.FindStringSubmatch(string(out))
+	if m == nil {
+		return JavaRuntime{}, fmt.Errorf("cannot parse java version output")
+	}
+	major, err := javaMajorVersion(m[1])
+	if err != nil {
+		return JavaRuntime{}, err
+	}
+	return JavaRuntime{Major: major, Version: m[1], Path: path}, nil
+}
+
+// javaMajorVersion "1.8.0_361" -> 8, "17.0.2" -> 17, "21" -> 21
+func javaMajorVersion(v string) (int, error) {
+	parts := strings.FieldsFunc(v, func(r rune) bool { return r == '.' || r == '_' || r == '-' || r == '+' })
+	if len(parts) == 0 {
+		return 0, fmt.Errorf("empty java version")
+	}
+	if parts[0] == "1" && len(parts) > 1 {
+		return strconv.Atoi(parts[1])
+	}
+	return strconv.Atoi(parts[0])
+}
+
+// parseJavaVersionMap 格式："1.16.5=8-15,1.17.1=16-21,*=21"，要由小到大排；只寫一個數字代表沒有上限
+func parseJavaVersionMap(raw string) ([]javaRequirement, error) {
+	var reqs []javaRequirement
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("bad entry %q", item)
+		}
+		minStr, maxStr, hasMax := strings.Cut(strings.TrimSpace(kv[1]), "-")
+		major, err := strconv.Atoi(strings.TrimSpace(minStr))
+		if err != nil {
+			return nil, fmt.Errorf("bad java major in %q", item)
+		}
+		maxMajor := 0
+		if hasMax {
+			if maxMajor, err = strconv.Atoi(strings.TrimSpace(maxStr)); err != nil || maxMajor < major {
+				return nil, fmt.Errorf("bad java major range in %q", item)
+			}
+		}
+		upTo := strings.TrimSpace(kv[0])
+		if upTo == "*" {
+			upTo = ""
+		}
+		reqs = append(reqs, javaRequirement{UpTo: upTo, Major: major, MaxMajor: maxMajor})
+	}
+	return reqs, nil
+}
+
+// parseMinecraftVersion "1.20.4" -> [1 20 4]，"1.20.5-rc1" 會當作 1.20.5；快照這類無法解析的回傳 nil
+func parseMinecraftVersion(v string) []int {
+	v = strings.SplitN(v, "-", 2)[0]
+	var out []int
+	for _, p := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+func compareVersion(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// RequiredJavaRange 回傳該 Minecraft 版本可以用的 Java 主版本範圍，max 為 0 代表沒有上限
+// 解析不了的版本 (例如快照) 視為最新版
+func RequiredJavaRange(mcVersion string) (int, int) {
+	javaMu.RLock()
+	defer javaMu.RUnlock()
+	ver := parseMinecraftVersion(mcVersion)
+	var last javaRequirement
+	for _, r := range javaRequirements {
+		last = r
+		if r.UpTo == "" {
+			break
+		}
+		if ver != nil && compareVersion(ver, parseMinecraftVersion(r.UpTo)) <= 0 {
+			break
+		}
+	}
+	return last.Major, last.MaxMajor
+}
+
+func ListJavaRuntimes() []JavaRuntime {
+	javaMu.RLock()
+	defer javaMu.RUnlock()
+	out := make([]JavaRuntime, len(javaRuntimes))
+	copy(out, javaRuntimes)
+	return out
+}
+
+// SelectJavaRuntime override > 0 時只接受該主版本；否則挑範圍內最低的主版本，範圍內都沒有就回傳錯誤
+func SelectJavaRuntime(mcVersion string, override int) (JavaRuntime, error) {
+	runtimes := ListJavaRuntimes()
+	if override > 0 {
+		for _, rt := range runtimes {
+			if rt.Major == override {
+				return rt, nil
+			}
+		}
+		return JavaRuntime{}, fmt.Errorf("%w: java %d is not installed", ErrNoJavaRuntime, override)
+	}
+
+	required, maxMajor := RequiredJavaRange(mcVersion)
+	for _, rt := range runtimes {
+		if rt.Major >= required && (maxMajor == 0 || rt.Major <= maxMajor) {
+			return rt, nil
+		}
+	}
+	if maxMajor > 0 {
+		return JavaRuntime{}, fmt.Errorf("%w: minecraft %s requires java %d to %d", ErrNoJavaRuntime, mcVersion, required, maxMajor)
+	}
+	return JavaRuntime{}, fmt.Errorf("%w: minecraft %s requires java %d or newer", ErrNoJavaRuntime, mcVersion, required)
+}
+
+var serverIDVersionRe = regexp.MustCompile(`^mcs[a-z]v-(.+)-\d+-OID-.+$`)
+
+// ServerVersionFromID 舊資料沒有存版本，從 server id 裡拆出來
+func ServerVersionFromID(serverID string) string {
+	m := serverIDVersionRe.FindStringSubmatch(serverID)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
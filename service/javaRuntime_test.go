@@ -0,0 +1,62 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSelectJavaRuntime(t *testing.T) {
+	reqs, err := parseJavaVersionMap("1.16.5=8-15,1.17.1=16-21,1.20.4=17-21,*=21")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldRuntimes, oldReqs := javaRuntimes, javaRequirements
+	defer func() { javaRuntimes, javaRequirements = oldRuntimes, oldReqs }()
+	javaRequirements = reqs
+
+	tests := []struct {
+		name      string
+		installed []int
+		version   string
+		override  int
+		want      int // 0 代表要回傳 ErrNoJavaRuntime
+	}{
+		{"old version picks java 8", []int{8, 17, 21}, "1.12.2", 0, 8},
+		{"old version without java 8", []int{17, 21}, "1.12.2", 0, 0},
+		{"1.17 picks lowest in range", []int{8, 17, 21}, "1.17.1", 0, 17},
+		{"1.20.4 on 21 only", []int{21}, "1.20.4", 0, 21},
+		{"latest needs 21", []int{8, 17}, "1.21.1", 0, 0},
+		{"snapshot treated as latest", []int{17, 21}, "24w14a", 0, 21},
+		{"override", []int{8, 17, 21}, "1.20.4", 21, 21},
+		{"override not installed", []int{17}, "1.20.4", 21, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			javaRuntimes = nil
+			for _, major := range tt.installed {
+				javaRuntimes = append(javaRuntimes, JavaRuntime{Major: major})
+			}
+			rt, err := SelectJavaRuntime(tt.version, tt.override)
+			if tt.want == 0 {
+				if !errors.Is(err, ErrNoJavaRuntime) {
+					t.Fatalf("expected ErrNoJavaRuntime, got java %d (%v)", rt.Major, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if rt.Major != tt.want {
+				t.Fatalf("got java %d, want %d", rt.Major, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJavaVersionMapRejectsBadRange(t *testing.T) {
+	for _, raw := range []string{"1.16.5=15-8", "1.16.5=8-x", "1.16.5"} {
+		if _, err := parseJavaVersionMap(raw); err == nil {
+			t.Errorf("%q: expected an error", raw)
+		}
+	}
+}
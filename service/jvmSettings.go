@@ -0,0 +1,219 @@
+// service/jvmSettings.go
+// 每台伺服器的 heap 與 JVM 參數：驗證、預設組合
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"regexp"
+	"strings"
+)
+
+const (
+	MinHeapMB   = 512
+	MaxJvmFlags = 64
+)
+
+var ErrInvalidJvmSettings = errors.New("invalid jvm settings")
+
+type JvmPreset struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Flags       []string `json:"flags"`
+}
+
+// Aikar's flags，參考 https://docs.papermc.io/paper/aikars-flags
+var aikarFlags = []string{
+	"-XX:+UseG1GC",
+	"-XX:+ParallelRefProcEnabled",
+	"-XX:MaxGCPauseMillis=200",
+	"-XX:+UnlockExperimentalVMOptions",
+	"-XX:+DisableExplicitGC",
+	"-XX:+AlwaysPreTouch",
+	"-XX:G1NewSizePercent=30",
+	"-XX:G1MaxNewSizePercent=40",
+	"-XX:G1HeapRegionSize=8M",
+	"-XX:G1ReservePercent=20",
+	"-XX:G1HeapWastePercent=5",
+	"-XX:G1MixedGCCountTarget=4",
+	"-XX:InitiatingHeapOccupancyPercent=15",
+	"-XX:G1MixedGCLiveThresholdPercent=90",
+	"-XX:G1RSetUpdatingPauseTimePercent=5",
+	"-XX:SurvivorRatio=32",
+	"-XX:+PerfDisableSharedMem",
+	"-XX:MaxTenuringThreshold=1",
+	"-Dusing.aikars.flags=https://mcflags.emc.gs",
+	"-Daikars.new.flags=true",
+}
+
+// heap 超過 12G 時 Aikar 建議調整的幾個值
+var aikarLargeOverrides = map[string]string{
+	"-XX:G1NewSizePercent=":               "40",
+	"-XX:G1MaxNewSizePercent=":            "50",
+	"-XX:G1HeapRegionSize=":               "16M",
+	"-XX:G1ReservePercent=":               "15",
+	"-XX:InitiatingHeapOccupancyPercent=": "20",
+}
+
+var JvmPresets = []JvmPreset{
+	{Name: "default", Description: "No extra JVM flags", Flags: []string{}},
+	{Name: "aikar", Description: "Aikar's G1GC flags, for heaps up to 12G", Flags: aikarFlags},
+	{Name: "aikar-large", Description: "Aikar's G1GC flags tuned for heaps over 12G", Flags: aikarLargeFlags()},
+}
+
+func aikarLargeFlags() []string {
+	flags := make([]string, len(aikarFlags))
+	for i, f := range aikarFlags {
+		flags[i] = f
+		for prefix, v := range aikarLargeOverrides {
+			if strings.HasPrefix(f, prefix) {
+				flags[i] = prefix + v
+			}
+		}
+	}
+	return flags
+}
+
+func GetJvmPreset(name string) (JvmPreset, bool) {
+	for _, p := range JvmPresets {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return JvmPreset{}, false
+}
+
+// MaxMemoryForRole 回傳該角色單一伺服器可以設定的最大 heap (MB)
+func MaxMemoryForRole(role int) int {
+	switch {
+	case role >= common.RoleRootUser:
+		return common.MaxMemoryRootUser
+	case role >= common.RoleAdminUser:
+		return common.MaxMemoryAdminUser
+	default:
+		return common.MaxMemoryCommonUser
+	}
+}
+
+// 允許的 -XX 開關 (-XX:+Name / -XX:-Name)，只有 GC 與效能調校
+var allowedJvmSwitches = map[string]bool{
+	"UseG1GC":                     true,
+	"UseZGC":                      true,
+	"ZGenerational":               true,
+	"UseShenandoahGC":             true,
+	"UseParallelGC":               true,
+	"ParallelRefProcEnabled":      true,
+	"UnlockExperimentalVMOptions": true,
+	"DisableExplicitGC":           true,
+	"AlwaysPreTouch":              true,
+	"PerfDisableSharedMem":        true,
+	"UseStringDeduplication":      true,
+	"UseNUMA":                     true,
+	"UseTransparentHugePages":     true,
+	"OptimizeStringConcat":        true,
+	"UseCompressedOops":           true,
+}
+
+// 允許的 -XX:Name=value，值只能是數字 (可帶 K/M/G)；heap 大小相關的一律不在這裡，只能用 max_memory / min_memory
+var allowedJvmOptions = map[string]bool{
+	"MaxGCPauseMillis":               true,
+	"G1NewSizePercent":               true,
+	"G1MaxNewSizePercent":            true,
+	"G1HeapRegionSize":               true,
+	"G1ReservePercent":               true,
+	"G1HeapWastePercent":             true,
+	"G1MixedGCCountTarget":           true,
+	"InitiatingHeapOccupancyPercent": true,
+	"G1MixedGCLiveThresholdPercent":  true,
+	"G1RSetUpdatingPauseTimePercent": true,
+	"SurvivorRatio":                  true,
+	"MaxTenuringThreshold":           true,
+	"ParallelGCThreads":              true,
+	"ConcGCThreads":                  true,
+}
+
+// 允許的 -D 系統屬性，會讀檔 (log4j 設定檔之類) 或改路徑的都不開放
+var allowedJvmProperties = map[string]bool{
+	"using.aikars.flags":        true,
+	"aikars.new.flags":          true,
+	"file.encoding":             true,
+	"sun.stdout.encoding":       true,
+	"sun.stderr.encoding":       true,
+	"user.timezone":             true,
+	"user.language":             true,
+	"user.country":              true,
+	"java.net.preferIPv4Stack":  true,
+	"java.awt.headless":         true,
+	"log4j2.formatMsgNoLookups": true,
+	"terminal.jline":            true,
+	"terminal.ansi":             true,
+}
+
+var (
+	jvmOptionValueRe   = regexp.MustCompile(`^[0-9]{1,10}[kKmMgG]?$`)
+	jvmPropertyValueRe = regexp.MustCompile(`^[A-Za-z0-9._:/+-]{0,200}$`)
+)
+
+// validateJvmFlag 只接受白名單裡的參數
+func validateJvmFlag(f string) error {
+	switch {
+	case strings.HasPrefix(f, "-XX:+") || strings.HasPrefix(f, "-XX:-"):
+		if allowedJvmSwitches[f[5:]] {
+			return nil
+		}
+	case strings.HasPrefix(f, "-XX:"):
+		name, value, ok := strings.Cut(f[4:], "=")
+		if ok && allowedJvmOptions[name] && jvmOptionValueRe.MatchString(value) {
+			return nil
+		}
+	case strings.HasPrefix(f, "-D"):
+		key, value, _ := strings.Cut(f[2:], "=")
+		if allowedJvmProperties[key] && jvmPropertyValueRe.MatchString(value) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: flag %q is not allowed", ErrInvalidJvmSettings, f)
+}
+
+// ValidateJvmSettings heap 單位是 MB；flags 只接受白名單裡的 GC 調校參數，heap 大小一律由欄位決定
+func ValidateJvmSettings(role, maxMemory, minMemory int, flags []string) error {
+	limit := MaxMemoryForRole(role)
+	if maxMemory < MinHeapMB || maxMemory > limit {
+		return fmt.Errorf("%w: max memory must be between %dM and %dM", ErrInvalidJvmSettings, MinHeapMB, limit)
+	}
+	if minMemory < MinHeapMB || minMemory > maxMemory {
+		return fmt.Errorf("%w: min memory must be between %dM and max memory", ErrInvalidJvmSettings, MinHeapMB)
+	}
+	return ValidateJvmFlags(flags)
+}
+
+// ValidateJvmFlags 啟動前也會再檢查一次，擋掉舊版存進 DB 的參數
+func ValidateJvmFlags(flags []string) error {
+	if len(flags) > MaxJvmFlags {
+		return fmt.Errorf("%w: too many jvm flags", ErrInvalidJvmSettings)
+	}
+	for _, f := range flags {
+		if len(f) > 256 || strings.ContainsAny(f, " \t\r\n") {
+			return fmt.Errorf("%w: bad flag %q", ErrInvalidJvmSettings, f)
+		}
+		if err := validateJvmFlag(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FormatMemory 把 MB 轉成 -Xmx/-Xms 用的字串
+func FormatMemory(mb int) string {
+	return fmt.Sprintf("%dM", mb)
+}
+
+func ParseJvmFlags(s string) []string {
+	return strings.Fields(s)
+}
+
+func JoinJvmFlags(flags []string) string {
+	return strings.Join(flags, " ")
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"errors"
+	"go-backend/common"
+	"testing"
+)
+
+func TestValidateJvmSettings(t *testing.T) {
+	common.MaxMemoryCommonUser = 4096
+	common.MaxMemoryAdminUser = 8192
+	common.MaxMemoryRootUser = 16384
+
+	tests := []struct {
+		name  string
+		role  int
+		max   int
+		min   int
+		flags []string
+		ok    bool
+	}{
+		{"no flags", common.RoleCommonUser, 2048, 1024, nil, true},
+		{"over role limit", common.RoleCommonUser, 8192, 1024, nil, false},
+		{"admin limit", common.RoleAdminUser, 8192, 1024, nil, true},
+		{"below minimum", common.RoleCommonUser, 256, 256, nil, false},
+		{"min above max", common.RoleCommonUser, 1024, 2048, nil, false},
+		{"g1 tuning", common.RoleCommonUser, 2048, 1024, []string{"-XX:+UseG1GC", "-XX:MaxGCPauseMillis=200", "-XX:G1HeapRegionSize=8M"}, true},
+		{"allowed property", common.RoleCommonUser, 2048, 1024, []string{"-Dfile.encoding=UTF-8"}, true},
+		{"max heap size", common.RoleCommonUser, 2048, 1024, []string{"-XX:MaxHeapSize=64g"}, false},
+		{"initial heap size", common.RoleCommonUser, 2048, 1024, []string{"-XX:InitialHeapSize=1g"}, false},
+		{"ram percentage", common.RoleCommonUser, 2048, 1024, []string{"-XX:MaxRAMPercentage=100"}, false},
+		{"xmx", common.RoleCommonUser, 2048, 1024, []string{"-Xmx64g"}, false},
+		{"on oom command", common.RoleCommonUser, 2048, 1024, []string{"-XX:OnOutOfMemoryError=sh${IFS}-c${IFS}id"}, false},
+		{"on error command", common.RoleCommonUser, 2048, 1024, []string{"-XX:OnError=id"}, false},
+		{"error file", common.RoleCommonUser, 2048, 1024, []string{"-XX:ErrorFile=/tmp/x"}, false},
+		{"flags file", common.RoleCommonUser, 2048, 1024, []string{"-XX:Flags=/etc/passwd"}, false},
+		{"vm options file", common.RoleCommonUser, 2048, 1024, []string{"-XX:VMOptionsFile=/tmp/x"}, false},
+		{"allowed option bad value", common.RoleCommonUser, 2048, 1024, []string{"-XX:MaxGCPauseMillis=/tmp"}, false},
+		{"unknown switch", common.RoleCommonUser, 2048, 1024, []string{"-XX:+HeapDumpOnOutOfMemoryError"}, false},
+		{"file property", common.RoleCommonUser, 2048, 1024, []string{"-Dlog4j.configurationFile=/tmp/x.xml"}, false},
+		{"javaagent", common.RoleCommonUser, 2048, 1024, []string{"-javaagent:/tmp/a.jar"}, false},
+		{"whitespace", common.RoleCommonUser, 2048, 1024, []string{"-XX:+UseG1GC -Xmx64g"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateJvmSettings(tt.role, tt.max, tt.min, tt.flags)
+			if tt.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidJvmSettings) {
+				t.Fatalf("expected ErrInvalidJvmSettings, got %v", err)
+			}
+		})
+	}
+}
+
+func TestJvmPresetsAreAllowed(t *testing.T) {
+	for _, p := range JvmPresets {
+		if err := ValidateJvmFlags(p.Flags); err != nil {
+			t.Errorf("preset %s: %v", p.Name, err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+// service/keyLocks.go
+// 以字串為 key 的鎖：同一台伺服器 (或同一個目錄) 的操作要排隊，不同伺服器互不影響
+
+package service
+
+import "sync"
+
+type keyLocks struct {
+	m sync.Map // key -> *sync.Mutex
+}
+
+func (l *keyLocks) get(key string) *sync.Mutex {
+	v, _ := l.m.LoadOrStore(key, &sync.Mutex{})
+	return v.(*sync.Mutex)
+}
+
+// lock 等到拿到鎖為止，回傳 unlock
+func (l *keyLocks) lock(key string) func() {
+	mu := l.get(key)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// tryLock 已經被鎖住就直接回傳 false
+func (l *keyLocks) tryLock(key string) (func(), bool) {
+	mu := l.get(key)
+	if !mu.TryLock() {
+		return nil, false
+	}
+	return mu.Unlock, true
+}
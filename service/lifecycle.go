@@ -0,0 +1,110 @@
+// service/lifecycle.go
+// 伺服器生命週期狀態機：created -> starting -> running -> stopping -> stopped / crashed
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+type ServerState string
+
+const (
+	StateCreated  ServerState = "created"
+	StateStarting ServerState = "starting"
+	StateRunning  ServerState = "running"
+	StateStopping ServerState = "stopping"
+	StateStopped  ServerState = "stopped"
+	StateCrashed  ServerState = "crashed"
+)
+
+var ErrInvalidTransition = errors.New("invalid server state transition")
+
+var allowedTransitions = map[ServerState][]ServerState{
+	StateCreated:  {StateStarting, StateRunning}, // created -> running 只有重新接管時
+	StateStarting: {StateRunning, StateStopping, StateStopped, StateCrashed},
+	StateRunning:  {StateStopping, StateStopped, StateCrashed},
+	StateStopping: {StateStopped, StateCrashed},
+	StateStopped:  {StateStarting},
+	StateCrashed:  {StateStarting},
+}
+
+// vanilla / Fabric 開好時會印：[Server thread/INFO]: Done (3.456s)! For help, type "help"
+var serverReadyRe = regexp.MustCompile(`Done \([0-9.,]+s\)!`)
+
+// Alive process 還在 (包含開機中與關機中)
+func (st ServerState) Alive() bool {
+	return st == StateStarting || st == StateRunning || st == StateStopping
+}
+
+func canTransition(from, to ServerState) bool {
+	for _, s := range allowedTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ServerStatusInfo GetStatus 回傳的內容
+type ServerStatusInfo struct {
+	State        ServerState `json:"state"`
+	Since        time.Time   `json:"since"`
+	StartedAt    *time.Time  `json:"started_at,omitempty"`
+	BootDuration float64     `json:"boot_duration"` // 秒，還沒開好是 0
+	ExitCode     *int        `json:"exit_code,omitempty"`
+}
+
+// transitionWithOutLock 呼叫時必須持有 s.mu
+func (s *Server) transitionWithOutLock(to ServerState) error {
+	if !canTransition(s.state, to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, s.state, to)
+	}
+	s.state = to
+	s.stateSince = time.Now()
+	return nil
+}
+
+// markReady console 印出 Done 之後 starting -> running
+func (s *Server) markReady() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != StateStarting {
+		return
+	}
+	if err := s.transitionWithOutLock(StateRunning); err == nil {
+		s.bootDuration = s.stateSince.Sub(s.startedAt)
+	}
+}
+
+func (s *Server) State() ServerState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state
+}
+
+func (s *Server) IsAlive() bool {
+	return s.State().Alive()
+}
+
+func (s *Server) StatusInfo() ServerStatusInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	info := ServerStatusInfo{
+		State:        s.state,
+		Since:        s.stateSince,
+		BootDuration: s.bootDuration.Seconds(),
+	}
+	if !s.startedAt.IsZero() {
+		startedAt := s.startedAt
+		info.StartedAt = &startedAt
+	}
+	if !s.exit.at.IsZero() && !s.state.Alive() && s.exit.code != exitCodeUnknown {
+		code := s.exit.code
+		info.ExitCode = &code
+	}
+	return info
+}
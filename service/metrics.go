@@ -0,0 +1,190 @@
+// service/metrics.go
+// 定時取樣每台伺服器 java process tree 的資源用量，存一段短的時間序列給前端畫圖
+
+package service
+
+import (
+	"fmt"
+	"go-backend/common"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// MetricsSample 一次取樣的結果
+type MetricsSample struct {
+	At         time.Time    `json:"at"`
+	Processes  int          `json:"processes"`   // process tree 裡的 process 數
+	RSS        uint64       `json:"rss"`         // bytes
+	CPUTime    float64      `json:"cpu_time"`    // 累計秒數 (user + system)
+	CPUPercent float64      `json:"cpu_percent"` // 跟上一次取樣比，100 = 一個核心跑滿
+	Threads    int          `json:"threads"`
+	FDs        int          `json:"fds"`
+	DiskUsage  int64        `json:"disk_usage"` // workDir 大小 bytes
+	Cgroup     *CgroupUsage `json:"cgroup,omitempty"`
+}
+
+// processUsage 平台相關的取樣結果，見 metrics_linux.go
+type processUsage struct {
+	Processes int
+	RSS       uint64
+	CPUTime   float64
+	Threads   int
+	FDs       int
+}
+
+// 算目錄大小比較花時間，不用每次取樣都算
+const diskUsageInterval = time.Minute
+
+type metricsSeries struct {
+	samples  []MetricsSample // 固定長度的環狀陣列
+	next     int
+	full     bool
+	lastPid  int
+	diskAt   time.Time
+	diskSize int64
+	mu       sync.Mutex
+}
+
+func newMetricsSeries(size int) *metricsSeries {
+	if size <= 0 {
+		size = 1
+	}
+	return &metricsSeries{samples: make([]MetricsSample, size)}
+}
+
+func (m *metricsSeries) add(sample MetricsSample) {
+	m.samples[m.next] = sample
+	m.next = (m.next + 1) % len(m.samples)
+	if m.next == 0 {
+		m.full = true
+	}
+}
+
+// last 呼叫時必須持有 m.mu
+func (m *metricsSeries) last() (MetricsSample, bool) {
+	if !m.full && m.next == 0 {
+		return MetricsSample{}, false
+	}
+	i := (m.next - 1 + len(m.samples)) % len(m.samples)
+	return m.samples[i], true
+}
+
+// History 由舊到新
+func (m *metricsSeries) History() []MetricsSample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.full {
+		out := make([]MetricsSample, m.next)
+		copy(out, m.samples[:m.next])
+		return out
+	}
+	out := make([]MetricsSample, 0, len(m.samples))
+	out = append(out, m.samples[m.next:]...)
+	return append(out, m.samples[:m.next]...)
+}
+
+func (m *metricsSeries) Current() *MetricsSample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.last(); ok {
+		return &s
+	}
+	return nil
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// sampleMetrics 取樣一次，process 不在就不記
+func (s *Server) sampleMetrics(now time.Time) error {
+	s.mu.RLock()
+	pid, alive, workDir := s.pid, s.state.Alive(), s.workDir
+	s.mu.RUnlock()
+	if !alive || pid <= 0 {
+		return nil
+	}
+
+	usage, err := readProcessTreeUsage(pid)
+	if err != nil {
+		return err
+	}
+
+	cgroupUsage := s.CgroupUsage()
+
+	m := s.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.diskAt.IsZero() || now.Sub(m.diskAt) >= diskUsageInterval {
+		m.diskSize = dirSize(workDir)
+		m.diskAt = now
+	}
+	sample := MetricsSample{
+		At:        now,
+		Processes: usage.Processes,
+		RSS:       usage.RSS,
+		CPUTime:   usage.CPUTime,
+		Threads:   usage.Threads,
+		FDs:       usage.FDs,
+		DiskUsage: m.diskSize,
+		Cgroup:    cgroupUsage,
+	}
+	// 重開之後 pid 換了，CPU time 從頭算，不能跟上一筆相減
+	if prev, ok := m.last(); ok && m.lastPid == pid {
+		if elapsed := now.Sub(prev.At).Seconds(); elapsed > 0 && usage.CPUTime >= prev.CPUTime {
+			sample.CPUPercent = (usage.CPUTime - prev.CPUTime) / elapsed * 100
+		}
+	}
+	m.lastPid = pid
+	m.add(sample)
+	return nil
+}
+
+// Metrics 回傳最新一筆與歷史
+func (s *Server) Metrics() (*MetricsSample, []MetricsSample) {
+	return s.metrics.Current(), s.metrics.History()
+}
+
+func (sm *ServerManager) GetServerMetrics(sid string) (*MetricsSample, []MetricsSample, error) {
+	sm.mu.RLock()
+	srv, exists := sm.servers[sid]
+	sm.mu.RUnlock()
+	if !exists {
+		return nil, nil, ErrNotFound
+	}
+	current, history := srv.Metrics()
+	return current, history, nil
+}
+
+func (sm *ServerManager) sampleMetricsLoop() {
+	ticker := time.NewTicker(time.Duration(common.MetricsInterval) * time.Second)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		sm.mu.RLock()
+		servers := make([]*Server, 0, len(sm.servers))
+		for _, srv := range sm.servers {
+			servers = append(servers, srv)
+		}
+		sm.mu.RUnlock()
+
+		for _, srv := range servers {
+			if err := srv.sampleMetrics(now); err != nil {
+				common.SysDebug(fmt.Sprintf("server %s metrics error: %s", srv.sid, err.Error()))
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+//go:build linux
+
+// service/metrics_linux.go
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Linux 上 USER_HZ 幾乎都是 100，不用 cgo 去問 sysconf
+const clockTicks = 100
+
+type procStat struct {
+	ppid    int
+	utime   uint64
+	stime   uint64
+	threads int
+	rss     uint64 // pages
+}
+
+// readProcStat 解析 /proc/<pid>/stat，comm 可能有空白所以從最後一個 ')' 後面開始切
+func readProcStat(pid int) (procStat, error) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return procStat{}, err
+	}
+	s := string(data)
+	i := strings.LastIndexByte(s, ')')
+	if i < 0 {
+		return procStat{}, fmt.Errorf("bad /proc/%d/stat", pid)
+	}
+	// fields[0] 是第 3 欄 state
+	fields := strings.Fields(s[i+1:])
+	if len(fields) < 22 {
+		return procStat{}, fmt.Errorf("bad /proc/%d/stat", pid)
+	}
+	var st procStat
+	st.ppid, _ = strconv.Atoi(fields[1])
+	st.utime, _ = strconv.ParseUint(fields[11], 10, 64)
+	st.stime, _ = strconv.ParseUint(fields[12], 10, 64)
+	st.threads, _ = strconv.Atoi(fields[17])
+	st.rss, _ = strconv.ParseUint(fields[21], 10, 64)
+	return st, nil
+}
+
+// processTree 回傳 pid 本身和所有子孫
+func processTree(root int) []int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return []int{root}
+	}
+	children := map[int][]int{}
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		st, err := readProcStat(pid)
+		if err != nil {
+			continue
+		}
+		children[st.ppid] = append(children[st.ppid], pid)
+	}
+	tree := []int{root}
+	for i := 0; i < len(tree); i++ {
+		tree = append(tree, children[tree[i]]...)
+	}
+	return tree
+}
+
+func readProcessTreeUsage(pid int) (processUsage, error) {
+	root, err := readProcStat(pid)
+	if err != nil {
+		return processUsage{}, err
+	}
+	pageSize := uint64(os.Getpagesize())
+	var usage processUsage
+	for _, p := range processTree(pid) {
+		st := root
+		if p != pid {
+			if st, err = readProcStat(p); err != nil {
+				// 取樣途中結束的子 process
+				continue
+			}
+		}
+		usage.Processes++
+		usage.RSS += st.rss * pageSize
+		usage.CPUTime += float64(st.utime+st.stime) / clockTicks
+		usage.Threads += st.threads
+		if fds, err := os.ReadDir(filepath.Join("/proc", strconv.Itoa(p), "fd")); err == nil {
+			usage.FDs += len(fds)
+		}
+	}
+	return usage, nil
+}
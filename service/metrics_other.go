@@ -0,0 +1,12 @@
+//go:build !linux
+
+// service/metrics_other.go
+
+package service
+
+import "errors"
+
+// 沒有 /proc 的平台先不支援
+func readProcessTreeUsage(pid int) (processUsage, error) {
+	return processUsage{}, errors.New("process metrics are only supported on linux")
+}
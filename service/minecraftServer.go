@@ -2,12 +2,12 @@
 package service
 
 import (
-	"encoding/json"
 	"fmt"
 	"go-backend/common"
-	"net/http"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 type CreateServerRequest struct {
@@ -38,8 +38,8 @@ func NewServerService(mgr *ServerManager) *ServerService {
 	return &ServerService{mgr: mgr}
 }
 
-func (s *ServerService) Start(sid, oid, workDir, maxMem, minMem string, args []string) (*Server, error) {
-	return s.mgr.StartServer(sid, oid, workDir, maxMem, minMem, args)
+func (s *ServerService) Start(sid, oid, workDir string, launch LaunchConfig) (*Server, error) {
+	return s.mgr.StartServer(sid, oid, workDir, launch)
 }
 
 func (s *ServerService) Stop(sid string) error {
@@ -50,6 +50,30 @@ func (s *ServerService) Status(sid string) (string, error) {
 	return s.mgr.GetServerStatus(sid)
 }
 
+func (s *ServerService) StatusInfo(sid string) ServerStatusInfo {
+	return s.mgr.GetServerStatusInfo(sid)
+}
+
+func (s *ServerService) Ping(sid string) (*PingResult, error) {
+	return s.mgr.PingServer(sid)
+}
+
+func (s *ServerService) Metrics(sid string) (*MetricsSample, []MetricsSample, error) {
+	return s.mgr.GetServerMetrics(sid)
+}
+
+func (s *ServerService) ListPorts() (PortPoolInfo, error) {
+	return s.mgr.ListPorts()
+}
+
+func (s *ServerService) ReservePort(port int, note string) error {
+	return s.mgr.ReservePort(port, note)
+}
+
+func (s *ServerService) ReleasePort(port int) error {
+	return s.mgr.ReleasePort(port)
+}
+
 func (s *ServerService) OwnerCount(oid string) int {
 	return s.mgr.countByOwner(oid)
 }
@@ -58,50 +82,136 @@ func (s *ServerService) ReadLatestLog(sid string) (string, error) {
 	return s.mgr.ReadLatestLog(sid)
 }
 
-func (s *ServerService) SendCommand(sid string, command string) error {
+func (s *ServerService) SubscribeConsole(sid string) (string, <-chan string, func(), error) {
+	return s.mgr.SubscribeConsole(sid)
+}
+
+func (s *ServerService) SendCommand(sid string, command string) (CommandResult, error) {
 	return s.mgr.SendCommand(sid, command)
 }
 
-func (s *ServerService) Backup(sid, workDir string) error {
-	return s.mgr.BackUp(sid, workDir)
+func (s *ServerService) Backup(sid, workDir string, opts BackupOptions) (*BackupManifest, error) {
+	return s.mgr.BackUp(sid, workDir, opts)
+}
+
+func (s *ServerService) RestoreBackup(sid, workDir, name string) error {
+	return s.mgr.RestoreBackup(sid, workDir, name)
+}
+
+func (s *ServerService) Snapshot(sid, workDir string, opts BackupOptions) (*Snapshot, error) {
+	return s.mgr.SnapshotWorld(sid, workDir, opts)
+}
+
+func (s *ServerService) VerifySnapshots(sid, workDir string) (*RepoVerifyReport, error) {
+	return s.mgr.VerifyBackupRepo(sid, workDir)
+}
+
+func (s *ServerService) PruneSnapshots(sid, workDir string, retention BackupRetention) (*RepoPruneReport, error) {
+	return s.mgr.PruneBackupRepo(sid, workDir, retention)
+}
+
+func (s *ServerService) RestoreSnapshot(sid, workDir, id string) error {
+	return s.mgr.RestoreSnapshot(sid, workDir, id)
+}
+
+func (s *ServerService) ImportWorld(sid, workDir string, upload io.Reader) (*WorldImportResult, error) {
+	return s.mgr.ImportWorld(sid, workDir, upload)
+}
+
+func (s *ServerService) OpenWorldExport(sid, workDir string) (*WorldExport, error) {
+	return s.mgr.OpenWorldExport(sid, workDir)
+}
+
+func (s *ServerService) WriteFile(sid, workDir, path, content string) error {
+	return s.mgr.WriteServerTextFile(sid, workDir, path, content)
+}
+
+func (s *ServerService) UploadFile(sid, workDir, dir, filename string, overwrite bool, r io.Reader) (string, error) {
+	return s.mgr.SaveServerUpload(sid, workDir, dir, filename, overwrite, r)
+}
+
+func (s *ServerService) RenameFile(sid, workDir, from, to string) error {
+	return s.mgr.RenameServerFile(sid, workDir, from, to)
+}
+
+func (s *ServerService) DeleteFile(sid, workDir, path string) error {
+	return s.mgr.DeleteServerFile(sid, workDir, path)
+}
+
+func (s *ServerService) MakeDir(sid, workDir, path string) error {
+	return s.mgr.MakeServerDir(sid, workDir, path)
+}
+
+func (s *ServerService) PatchProperties(sid, workDir string, patch map[string]any, author uint) (*PropertyPatchResult, error) {
+	return s.mgr.PatchProperties(sid, workDir, patch, author)
+}
+
+func (s *ServerService) RollbackProperties(sid, workDir string, id, author uint) error {
+	return s.mgr.RollbackProperties(sid, workDir, id, author)
+}
+
+func (s *ServerService) AddPlayerListEntry(sid, workDir, list string, req PlayerListRequest) (*PlayerListResult, error) {
+	return s.mgr.AddPlayerListEntry(sid, workDir, list, req)
+}
+
+func (s *ServerService) RemovePlayerListEntry(sid, workDir, list string, req PlayerListRequest) (*PlayerListResult, error) {
+	return s.mgr.RemovePlayerListEntry(sid, workDir, list, req)
+}
+
+func (s *ServerService) InstallMod(sid, workDir, serverType, gameVersion, project, versionID string) ([]ModChange, error) {
+	return s.mgr.InstallMod(sid, workDir, serverType, gameVersion, project, versionID)
+}
+
+func (s *ServerService) UpdateMods(sid, workDir, serverType, gameVersion, projectID string) ([]ModChange, error) {
+	return s.mgr.UpdateMods(sid, workDir, serverType, gameVersion, projectID)
+}
+
+func (s *ServerService) RemoveMod(sid, workDir, projectID string, force bool) error {
+	return s.mgr.RemoveMod(sid, workDir, projectID, force)
 }
 
 func CreateServer(ownerID string, serverType string, serverVer string, fabricLoader string, fabricInstaller string) (string, error) {
-	var idPerFix, fURL, vURL string
+	var jar jarSpec
 	var err error
 
-	if fabricLoader == "" {
-		fabricLoader = common.LatestFabricLoaderVersion // 預設值
-	}
-
-	if fabricInstaller == "" {
-		fabricInstaller = common.LatestFabricInstallerVersion
+	// 沒有可用的 java 就不用下載了
+	if _, err = SelectJavaRuntime(serverVer, 0); err != nil {
+		return "", err
 	}
 
 	switch serverType {
 	case "Fabric":
-		idPerFix = "mcsfv-"
-		fURL = fmt.Sprintf(
-			"https://meta.fabricmc.net/v2/versions/loader/%s/%s/%s/server/jar",
-			serverVer, fabricLoader, fabricInstaller,
+		// 沒指定就用版本清單裡最新的穩定版
+		if fabricLoader == "" {
+			if fabricLoader, err = DefaultFabricLoader(); err != nil {
+				return "", fmt.Errorf("failed to get fabric loader version: %w", err)
+			}
+		}
+		if fabricInstaller == "" {
+			if fabricInstaller, err = DefaultFabricInstaller(); err != nil {
+				return "", fmt.Errorf("failed to get fabric installer version: %w", err)
+			}
+		}
+		fURL := fmt.Sprintf(
+			"%s/versions/loader/%s/%s/%s/server/jar",
+			common.FabricMetaURL, serverVer, fabricLoader, fabricInstaller,
 		)
+		jar = fabricJarSpec(serverVer, fabricLoader, fabricInstaller, fURL)
 	case "Vanilla":
-		if idPerFix == "" {
-			idPerFix = "mcsvv-"
-		}
-		url, ok := common.VanillaServerUrl[serverVer]
-		if !ok {
-			return "", fmt.Errorf("unsupported server version: %s", serverVer)
+		url, sha1, verr := VanillaServerJar(serverVer)
+		if verr != nil {
+			return "", fmt.Errorf("unsupported server version %s: %w", serverVer, verr)
 		}
-		vURL = url
+		jar = vanillaJarSpec(serverVer, url, sha1)
 	default:
 		return "", fmt.Errorf("unsupported server type: %s", serverType)
 	}
 
-	uid := common.GetRandomIntString(4)
-	serverID := idPerFix + serverVer + "-" + uid + "-" + "OID-" + ownerID
+	serverID, sysPath, err := newServerDir(serverType, serverVer, ownerID)
+	if err != nil {
+		return "", err
+	}
 
-	sysPath := filepath.Join(common.MinecraftServerPath, serverID)
 	// defer 一個清理機制：若後續 err != nil，就把 sysPath 刪掉
 	defer func() {
 		if err != nil {
@@ -112,22 +222,9 @@ func CreateServer(ownerID string, serverType string, serverVer string, fabricLoa
 		}
 	}()
 
-	if err = os.MkdirAll(sysPath, 0755); err != nil {
-		return "", fmt.Errorf("failed to create server directory %s: %w", sysPath, err)
-	}
-
-	if vURL != "" {
-		vanillaJarPath := filepath.Join(sysPath, "server.jar")
-		if err = common.DownloadFile(vanillaJarPath, vURL); err != nil {
-			return "", fmt.Errorf("failed to download vanilla server jar: %w", err)
-		}
-	}
-
-	if fURL != "" {
-		fabricInstallerPath := filepath.Join(sysPath, "server.jar")
-		if err = common.DownloadFile(fabricInstallerPath, fURL); err != nil {
-			return "", fmt.Errorf("failed to download fabric installer: %w", err)
-		}
+	// 同一個版本的 jar 只下載一次，之後從快取拿
+	if err = installServerJar(jar, filepath.Join(sysPath, "server.jar")); err != nil {
+		return "", fmt.Errorf("failed to install %s server jar: %w", strings.ToLower(serverType), err)
 	}
 
 	eulaPath := filepath.Join(sysPath, "eula.txt")
@@ -140,28 +237,26 @@ func CreateServer(ownerID string, serverType string, serverVer string, fabricLoa
 }
 
 func GetAllFabricVersions() ([]string, error) {
-	resp, err := http.Get("https://meta.fabricmc.net/v2/versions/game")
+	list, err := ListCatalogVersions(CatalogFabricGame)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get fabric versions, status code: %d", resp.StatusCode)
-	}
-
-	var gv []GameVersion
-	if err := json.NewDecoder(resp.Body).Decode(&gv); err != nil {
-		return nil, err
-	}
-
-	versions := make([]string, len(gv))
-	for i, v := range gv {
+	versions := make([]string, len(list))
+	for i, v := range list {
 		versions[i] = v.Version
 	}
 	return versions, nil
 }
 
+// GetAllVanillaVersions 版本 -> server jar 位址，還沒解析過的版本位址是空的 (建立伺服器時才解析)
 func GetAllVanillaVersions() (map[string]string, error) {
-	all := common.VanillaServerUrl
+	list, err := ListCatalogVersions(CatalogVanilla)
+	if err != nil {
+		return nil, err
+	}
+	all := make(map[string]string, len(list))
+	for _, v := range list {
+		all[v.Version] = v.ServerURL
+	}
 	return all, nil
 }
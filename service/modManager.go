@@ -0,0 +1,615 @@
+// service/modManager.go
+// Fabric 伺服器的模組管理：透過 Modrinth 相容的 API 搜尋、解析必要相依、下載到 mods/ 並驗證 hash
+
+package service
+
+import (
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"go-backend/model"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	modLoader      = "fabric"
+	modMaxFileSize = 512 << 20
+	modMaxDepth    = 16 // 相依解析的最大深度，避免循環相依
+)
+
+var (
+	ErrModsUnsupported   = errors.New("mods are only supported on Fabric servers")
+	ErrModNotFound       = errors.New("mod not found")
+	ErrModNoVersion      = errors.New("no compatible version for this game version and loader")
+	ErrModClientOnly     = errors.New("mod does not run on servers")
+	ErrModIncompatible   = errors.New("mod is incompatible with an installed mod")
+	ErrModHashMismatch   = errors.New("downloaded file hash mismatch")
+	ErrModRequired       = errors.New("mod is required by other installed mods")
+	ErrModIndexHTTP      = errors.New("mod index request failed")
+	ErrModNotInstalled   = errors.New("mod is not installed")
+	ErrInvalidModRequest = errors.New("invalid mod request")
+)
+
+var modHTTPClient = &http.Client{Timeout: 60 * time.Second}
+
+// 同一台伺服器的安裝、更新、移除要排隊
+var modLocks keyLocks
+
+// ---------------- Modrinth API ----------------
+
+type ModSearchHit struct {
+	ProjectID     string   `json:"project_id"`
+	Slug          string   `json:"slug"`
+	Title         string   `json:"title"`
+	Description   string   `json:"description"`
+	Author        string   `json:"author"`
+	Downloads     int64    `json:"downloads"`
+	IconURL       string   `json:"icon_url"`
+	Versions      []string `json:"versions"`
+	ServerSide    string   `json:"server_side"`
+	LatestVersion string   `json:"latest_version"`
+}
+
+type ModSearchResult struct {
+	Hits      []ModSearchHit `json:"hits"`
+	Offset    int            `json:"offset"`
+	Limit     int            `json:"limit"`
+	TotalHits int            `json:"total_hits"`
+}
+
+type modProject struct {
+	ID         string `json:"id"`
+	Slug       string `json:"slug"`
+	Title      string `json:"title"`
+	ServerSide string `json:"server_side"`
+}
+
+type modFile struct {
+	Hashes   map[string]string `json:"hashes"`
+	URL      string            `json:"url"`
+	Filename string            `json:"filename"`
+	Primary  bool              `json:"primary"`
+	Size     int64             `json:"size"`
+}
+
+type modDependency struct {
+	VersionID      string `json:"version_id"`
+	ProjectID      string `json:"project_id"`
+	DependencyType string `json:"dependency_type"` // required / optional / incompatible / embedded
+}
+
+type modVersion struct {
+	ID            string          `json:"id"`
+	ProjectID     string          `json:"project_id"`
+	Name          string          `json:"name"`
+	VersionNumber string          `json:"version_number"`
+	VersionType   string          `json:"version_type"`
+	DatePublished time.Time       `json:"date_published"`
+	GameVersions  []string        `json:"game_versions"`
+	Loaders       []string        `json:"loaders"`
+	Files         []modFile       `json:"files"`
+	Dependencies  []modDependency `json:"dependencies"`
+}
+
+// primaryFile 有標 primary 的優先，沒有就用第一個
+func (v *modVersion) primaryFile() (*modFile, error) {
+	if len(v.Files) == 0 {
+		return nil, fmt.Errorf("version %s has no files", v.ID)
+	}
+	for i := range v.Files {
+		if v.Files[i].Primary {
+			return &v.Files[i], nil
+		}
+	}
+	return &v.Files[0], nil
+}
+
+func (v *modVersion) requiredProjects() []string {
+	var ids []string
+	for _, d := range v.Dependencies {
+		if d.DependencyType == "required" && d.ProjectID != "" {
+			ids = append(ids, d.ProjectID)
+		}
+	}
+	return ids
+}
+
+func modIndexGet(path string, query url.Values, out any) error {
+	u := common.ModIndexURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	// Modrinth 要求帶可以辨識的 User-Agent
+	req.Header.Set("User-Agent", common.UserAgent())
+	resp, err := modHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrModIndexHTTP, err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrModNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s returned %s", ErrModIndexHTTP, path, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 16<<20)).Decode(out)
+}
+
+func jsonList(values ...string) string {
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+// SearchMods 只搜尋能在伺服器上跑的 Fabric 模組
+func SearchMods(query, gameVersion string, offset, limit int) (*ModSearchResult, error) {
+	facets := [][]string{
+		{"project_type:mod"},
+		{"categories:" + modLoader},
+		{"server_side:required", "server_side:optional"},
+	}
+	if gameVersion != "" {
+		facets = append(facets, []string{"versions:" + gameVersion})
+	}
+	facetJSON, _ := json.Marshal(facets)
+	q := url.Values{}
+	q.Set("query", query)
+	q.Set("facets", string(facetJSON))
+	q.Set("offset", fmt.Sprint(offset))
+	q.Set("limit", fmt.Sprint(limit))
+
+	var result ModSearchResult
+	if err := modIndexGet("/search", q, &result); err != nil {
+		return nil, err
+	}
+	if result.Hits == nil {
+		result.Hits = []ModSearchHit{}
+	}
+	return &result, nil
+}
+
+func getModProject(idOrSlug string) (*modProject, error) {
+	var p modProject
+	if err := modIndexGet("/project/"+url.PathEscape(idOrSlug), nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func getModVersion(id string) (*modVersion, error) {
+	var v modVersion
+	if err := modIndexGet("/version/"+url.PathEscape(id), nil, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// latestModVersion API 回傳的版本是新的在前，正式版優先，沒有才用 beta / alpha
+func latestModVersion(projectID, gameVersion string) (*modVersion, error) {
+	q := url.Values{}
+	q.Set("loaders", jsonList(modLoader))
+	q.Set("game_versions", jsonList(gameVersion))
+	var versions []modVersion
+	if err := modIndexGet("/project/"+url.PathEscape(projectID)+"/version", q, &versions); err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrModNoVersion, projectID)
+	}
+	for i := range versions {
+		if versions[i].VersionType == "release" {
+			return &versions[i], nil
+		}
+	}
+	return &versions[0], nil
+}
+
+// ---------------- 安裝計畫 ----------------
+
+type modPlanItem struct {
+	project      *modProject
+	version      *modVersion
+	asDependency bool
+}
+
+// resolveModPlan 從 root 開始把必要相依一路解析下去，已經裝好的 project 不會再裝
+// pinned 是指定要裝的版本 (空的就用最新的相容版本)
+func resolveModPlan(root, pinned, gameVersion string, installed map[string]model.MinecraftServerMod) ([]modPlanItem, error) {
+	var plan []modPlanItem
+	seen := map[string]bool{}
+
+	var visit func(projectID, versionID string, asDep bool, depth int) error
+	visit = func(projectID, versionID string, asDep bool, depth int) error {
+		if depth > modMaxDepth {
+			return fmt.Errorf("dependency chain too deep at %s", projectID)
+		}
+		var v *modVersion
+		var err error
+		if versionID != "" {
+			v, err = getModVersion(versionID)
+			if err == nil && !containsString(v.Loaders, modLoader) {
+				err = fmt.Errorf("%w: %s is not a Fabric version", ErrModNoVersion, v.VersionNumber)
+			}
+			// 使用者指定的版本要符合遊戲版本，相依指定的版本以作者為準
+			if err == nil && depth == 0 && !containsString(v.GameVersions, gameVersion) {
+				err = fmt.Errorf("%w: %s does not support %s", ErrModNoVersion, v.VersionNumber, gameVersion)
+			}
+		} else {
+			v, err = latestModVersion(projectID, gameVersion)
+		}
+		if err != nil {
+			return err
+		}
+		if seen[v.ProjectID] {
+			return nil
+		}
+		seen[v.ProjectID] = true
+
+		p, err := getModProject(v.ProjectID)
+		if err != nil {
+			return err
+		}
+		if p.ServerSide == "unsupported" {
+			return fmt.Errorf("%w: %s", ErrModClientOnly, p.Title)
+		}
+		for _, d := range v.Dependencies {
+			if d.DependencyType != "incompatible" {
+				continue
+			}
+			if _, ok := installed[d.ProjectID]; ok {
+				return fmt.Errorf("%w: %s conflicts with %s", ErrModIncompatible, p.Title, installed[d.ProjectID].Title)
+			}
+		}
+		plan = append(plan, modPlanItem{project: p, version: v, asDependency: asDep})
+
+		for _, d := range v.Dependencies {
+			if d.DependencyType != "required" {
+				continue
+			}
+			depProject := d.ProjectID
+			if depProject == "" && d.VersionID != "" {
+				dv, err := getModVersion(d.VersionID)
+				if err != nil {
+					return err
+				}
+				depProject = dv.ProjectID
+			}
+			if depProject == "" || seen[depProject] {
+				continue
+			}
+			if _, ok := installed[depProject]; ok {
+				continue
+			}
+			if err := visit(depProject, d.VersionID, true, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := visit(root, pinned, false, 0); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------------- 下載 ----------------
+
+func modsDir(workDir string) string {
+	return filepath.Join(workDir, "mods")
+}
+
+// safeModFileName 檔名只能是 mods/ 底下的 .jar
+func safeModFileName(name string) (string, error) {
+	base := filepath.Base(filepath.Clean("/" + strings.ReplaceAll(name, "\\", "/")))
+	if base != name || !strings.HasSuffix(strings.ToLower(base), ".jar") || strings.HasPrefix(base, ".") {
+		return "", fmt.Errorf("%w: bad file name %q", ErrInvalidModRequest, name)
+	}
+	return base, nil
+}
+
+// downloadModFile 先下載到暫存檔，sha512 (沒有就 sha1) 對得上才改名成正式檔名
+func downloadModFile(workDir string, f *modFile) (string, error) {
+	name, err := safeModFileName(f.Filename)
+	if err != nil {
+		return "", err
+	}
+	want512, want1 := strings.ToLower(f.Hashes["sha512"]), strings.ToLower(f.Hashes["sha1"])
+	if want512 == "" && want1 == "" {
+		return "", fmt.Errorf("%w: index did not provide a hash for %s", ErrModHashMismatch, name)
+	}
+	dir := modsDir(workDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, f.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", common.UserAgent())
+	resp, err := modHTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("download %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download %s: %s", name, resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	h512, h1 := sha512.New(), sha1.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h512, h1), io.LimitReader(resp.Body, modMaxFileSize+1))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", fmt.Errorf("download %s: %w", name, err)
+	}
+	if n > modMaxFileSize {
+		return "", fmt.Errorf("download %s: %w", name, ErrFileTooLarge)
+	}
+	if (want512 != "" && hex.EncodeToString(h512.Sum(nil)) != want512) ||
+		(want512 == "" && hex.EncodeToString(h1.Sum(nil)) != want1) {
+		return "", fmt.Errorf("%w: %s", ErrModHashMismatch, name)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h512.Sum(nil)), nil
+}
+
+// ---------------- ServerManager ----------------
+
+// InstalledMod 已安裝的模組，Missing 代表檔案被手動刪掉了
+type InstalledMod struct {
+	model.MinecraftServerMod
+	Missing bool `json:"missing"`
+}
+
+// ModChange 安裝 / 更新的結果
+type ModChange struct {
+	ProjectID    string `json:"project_id"`
+	Title        string `json:"title"`
+	From         string `json:"from,omitempty"`
+	To           string `json:"to"`
+	FileName     string `json:"file_name"`
+	AsDependency bool   `json:"as_dependency"`
+}
+
+func installedModMap(sid string) (map[string]model.MinecraftServerMod, error) {
+	mods, err := model.GetServerMods(sid)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]model.MinecraftServerMod{}
+	for _, mod := range mods {
+		m[mod.ProjectID] = mod
+	}
+	return m, nil
+}
+
+func ListInstalledMods(sid, workDir string) ([]InstalledMod, error) {
+	mods, err := model.GetServerMods(sid)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]InstalledMod, len(mods))
+	for i, m := range mods {
+		_, statErr := os.Stat(filepath.Join(modsDir(workDir), m.FileName))
+		out[i] = InstalledMod{MinecraftServerMod: m, Missing: os.IsNotExist(statErr)}
+	}
+	return out, nil
+}
+
+// installPlanItem 下載新檔，成功後才刪掉舊版本的檔案並更新紀錄，回傳存進 DB 的紀錄
+func installPlanItem(sid, workDir string, item modPlanItem, prev *model.MinecraftServerMod) (*ModChange, *model.MinecraftServerMod, error) {
+	f, err := item.version.primaryFile()
+	if err != nil {
+		return nil, nil, err
+	}
+	sum, err := downloadModFile(workDir, f)
+	if err != nil {
+		return nil, nil, err
+	}
+	change := &ModChange{
+		ProjectID:    item.project.ID,
+		Title:        item.project.Title,
+		To:           item.version.VersionNumber,
+		FileName:     f.Filename,
+		AsDependency: item.asDependency,
+	}
+	record := &model.MinecraftServerMod{
+		ServerID:      sid,
+		ProjectID:     item.project.ID,
+		Slug:          item.project.Slug,
+		Title:         item.project.Title,
+		VersionID:     item.version.ID,
+		VersionNumber: item.version.VersionNumber,
+		FileName:      f.Filename,
+		SHA512:        sum,
+		Size:          f.Size,
+		Dependencies:  strings.Join(item.version.requiredProjects(), ","),
+		AsDependency:  item.asDependency,
+	}
+	if prev != nil {
+		change.From = prev.VersionNumber
+		// 原本是手動裝的就維持手動
+		record.AsDependency = prev.AsDependency && item.asDependency
+		if prev.FileName != "" && prev.FileName != f.Filename {
+			_ = os.Remove(filepath.Join(modsDir(workDir), prev.FileName))
+		}
+	}
+	if err := model.SaveServerMod(record); err != nil {
+		return nil, nil, err
+	}
+	return change, record, nil
+}
+
+// InstallMod 安裝指定的模組 (project id 或 slug) 和它的必要相依，伺服器必須停止
+func (sm *ServerManager) InstallMod(sid, workDir, serverType, gameVersion, project, versionID string) ([]ModChange, error) {
+	if serverType != "Fabric" {
+		return nil, ErrModsUnsupported
+	}
+	if project == "" && versionID == "" {
+		return nil, fmt.Errorf("%w: project or version is required", ErrInvalidModRequest)
+	}
+	if sm.isAlive(sid) {
+		return nil, ErrServerRunning
+	}
+	unlock := modLocks.lock(sid)
+	defer unlock()
+
+	installed, err := installedModMap(sid)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := resolveModPlan(project, versionID, gameVersion, installed)
+	if err != nil {
+		return nil, err
+	}
+	changes := []ModChange{}
+	for _, item := range plan {
+		var prev *model.MinecraftServerMod
+		if m, ok := installed[item.project.ID]; ok {
+			prev = &m
+		}
+		change, _, err := installPlanItem(sid, workDir, item, prev)
+		if err != nil {
+			return changes, err
+		}
+		changes = append(changes, *change)
+	}
+	return changes, nil
+}
+
+// UpdateMods 把已安裝的模組 (projectID 空的話就是全部) 更新到最新的相容版本，新出現的必要相依也會一起裝
+func (sm *ServerManager) UpdateMods(sid, workDir, serverType, gameVersion, projectID string) ([]ModChange, error) {
+	if serverType != "Fabric" {
+		return nil, ErrModsUnsupported
+	}
+	if sm.isAlive(sid) {
+		return nil, ErrServerRunning
+	}
+	unlock := modLocks.lock(sid)
+	defer unlock()
+
+	installed, err := installedModMap(sid)
+	if err != nil {
+		return nil, err
+	}
+	targets := []model.MinecraftServerMod{}
+	if projectID != "" {
+		m, ok := installed[projectID]
+		if !ok {
+			return nil, ErrModNotInstalled
+		}
+		targets = append(targets, m)
+	} else {
+		for _, m := range installed {
+			targets = append(targets, m)
+		}
+	}
+
+	changes := []ModChange{}
+	for _, target := range targets {
+		// 前面的模組更新時可能已經順便更新過它，要用最新的紀錄
+		mod := installed[target.ProjectID]
+		latest, err := latestModVersion(mod.ProjectID, gameVersion)
+		if err != nil {
+			return changes, fmt.Errorf("%s: %w", mod.Title, err)
+		}
+		if latest.ID == mod.VersionID {
+			continue
+		}
+		// 自己不算已安裝，才會被放進計畫裡
+		others := map[string]model.MinecraftServerMod{}
+		for k, v := range installed {
+			if k != mod.ProjectID {
+				others[k] = v
+			}
+		}
+		plan, err := resolveModPlan(mod.ProjectID, latest.ID, gameVersion, others)
+		if err != nil {
+			return changes, fmt.Errorf("%s: %w", mod.Title, err)
+		}
+		for _, item := range plan {
+			var prev *model.MinecraftServerMod
+			if m, ok := installed[item.project.ID]; ok {
+				prev = &m
+			}
+			item.asDependency = item.asDependency || (prev != nil && prev.AsDependency)
+			change, record, err := installPlanItem(sid, workDir, item, prev)
+			if err != nil {
+				return changes, err
+			}
+			changes = append(changes, *change)
+			installed[item.project.ID] = *record
+		}
+	}
+	return changes, nil
+}
+
+// RemoveMod 其他模組還需要它的話要 force 才會刪
+func (sm *ServerManager) RemoveMod(sid, workDir, projectID string, force bool) error {
+	if sm.isAlive(sid) {
+		return ErrServerRunning
+	}
+	unlock := modLocks.lock(sid)
+	defer unlock()
+
+	mod, err := model.GetServerMod(sid, projectID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrModNotInstalled
+	}
+	if err != nil {
+		return err
+	}
+	if !force {
+		mods, err := model.GetServerMods(sid)
+		if err != nil {
+			return err
+		}
+		var users []string
+		for _, m := range mods {
+			if containsString(strings.Split(m.Dependencies, ","), projectID) {
+				users = append(users, m.Title)
+			}
+		}
+		if len(users) > 0 {
+			return fmt.Errorf("%w: %s", ErrModRequired, strings.Join(users, ", "))
+		}
+	}
+	if err := os.Remove(filepath.Join(modsDir(workDir), mod.FileName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return model.RemoveServerMod(sid, projectID)
+}
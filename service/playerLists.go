@@ -0,0 +1,352 @@
+// service/playerLists.go
+// whitelist / ops / 封鎖名單：伺服器開著時走指令 (伺服器自己會存檔)，關著時直接改 json
+
+package service
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	ListWhitelist     = "whitelist"
+	ListOps           = "ops"
+	ListBannedPlayers = "banned-players"
+	ListBannedIPs     = "banned-ips"
+)
+
+var (
+	ErrUnknownPlayerList = errors.New("unknown player list")
+	ErrInvalidPlayerName = errors.New("invalid player name")
+	ErrInvalidIP         = errors.New("invalid ip address")
+	ErrInvalidListEntry  = errors.New("invalid list entry")
+	ErrPlayerUnknown     = errors.New("player not found in usercache.json, add them while the server is running")
+	ErrEntryNotFound     = errors.New("entry not found")
+	ErrOpLevelRunning    = errors.New("op level can only be set while the server is stopped")
+)
+
+var (
+	playerNameRe = regexp.MustCompile(`^[A-Za-z0-9_]{1,16}$`)
+	playerUUIDRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{12}$`)
+)
+
+// 原版 json 裡的時間格式 (java 的 yyyy-MM-dd HH:mm:ss Z)
+const playerListTimeLayout = "2006-01-02 15:04:05 -0700"
+
+// PlayerListEntry 四種名單共用，欄位名稱跟原版 json 一樣
+type PlayerListEntry struct {
+	UUID                string `json:"uuid,omitempty"`
+	Name                string `json:"name,omitempty"`
+	IP                  string `json:"ip,omitempty"`
+	Level               int    `json:"level,omitempty"`
+	BypassesPlayerLimit *bool  `json:"bypassesPlayerLimit,omitempty"`
+	Created             string `json:"created,omitempty"`
+	Source              string `json:"source,omitempty"`
+	Expires             string `json:"expires,omitempty"`
+	Reason              string `json:"reason,omitempty"`
+}
+
+// PlayerListRequest 新增或移除，banned-ips 用 IP，其他用 Name (也可以直接給 UUID)
+type PlayerListRequest struct {
+	Name                string `json:"name"`
+	UUID                string `json:"uuid"`
+	IP                  string `json:"ip"`
+	Reason              string `json:"reason"`
+	Level               int    `json:"level"`
+	BypassesPlayerLimit bool   `json:"bypasses_player_limit"`
+}
+
+// PlayerListResult Via 是 "command" 或 "file"
+type PlayerListResult struct {
+	Via    string           `json:"via"`
+	Output string           `json:"output,omitempty"`
+	Entry  *PlayerListEntry `json:"entry,omitempty"`
+}
+
+var playerListLocks keyLocks
+
+func playerListPath(workDir, list string) (string, error) {
+	switch list {
+	case ListWhitelist, ListOps, ListBannedPlayers, ListBannedIPs:
+		return filepath.Join(workDir, list+".json"), nil
+	}
+	return "", ErrUnknownPlayerList
+}
+
+func readPlayerList(path string) ([]PlayerListEntry, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return []PlayerListEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries := []PlayerListEntry{}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return entries, nil
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", filepath.Base(path), err)
+	}
+	return entries, nil
+}
+
+// writePlayerList 原版用兩格縮排
+func writePlayerList(path string, entries []PlayerListEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func ListPlayerList(workDir, list string) ([]PlayerListEntry, error) {
+	path, err := playerListPath(workDir, list)
+	if err != nil {
+		return nil, err
+	}
+	return readPlayerList(path)
+}
+
+// OfflinePlayerUUID 離線模式的 UUID：MD5("OfflinePlayer:"+name) 的 version 3 UUID
+func OfflinePlayerUUID(name string) string {
+	h := md5.Sum([]byte("OfflinePlayer:" + name))
+	h[6] = h[6]&0x0f | 0x30
+	h[8] = h[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
+
+func formatUUID(raw string) string {
+	u := strings.ToLower(strings.ReplaceAll(raw, "-", ""))
+	return u[0:8] + "-" + u[8:12] + "-" + u[12:16] + "-" + u[16:20] + "-" + u[20:32]
+}
+
+// resolvePlayer 先查 usercache.json (名稱以它為準)，離線模式的伺服器才用推算的 UUID
+func resolvePlayer(workDir, name string) (string, string, error) {
+	if data, err := os.ReadFile(filepath.Join(workDir, "usercache.json")); err == nil {
+		var cache []struct {
+			Name string `json:"name"`
+			UUID string `json:"uuid"`
+		}
+		if json.Unmarshal(data, &cache) == nil {
+			for _, c := range cache {
+				if strings.EqualFold(c.Name, name) && playerUUIDRe.MatchString(c.UUID) {
+					return c.Name, formatUUID(c.UUID), nil
+				}
+			}
+		}
+	}
+	if props, err := readProperties(workDir); err == nil && props["online-mode"] == "false" {
+		return name, OfflinePlayerUUID(name), nil
+	}
+	return "", "", ErrPlayerUnknown
+}
+
+// sanitizeReason 指令只有一行，長度也限制一下
+func sanitizeReason(reason string) string {
+	reason = strings.Join(strings.Fields(reason), " ")
+	if len(reason) > 256 {
+		reason = reason[:256]
+	}
+	return reason
+}
+
+func validatePlayerListRequest(list string, req *PlayerListRequest) error {
+	req.Reason = sanitizeReason(req.Reason)
+	if list == ListBannedIPs {
+		ip := net.ParseIP(strings.TrimSpace(req.IP))
+		if ip == nil {
+			return ErrInvalidIP
+		}
+		req.IP = ip.String()
+		return nil
+	}
+	if req.UUID != "" && !playerUUIDRe.MatchString(req.UUID) {
+		return fmt.Errorf("%w: invalid uuid", ErrInvalidListEntry)
+	}
+	if !playerNameRe.MatchString(req.Name) {
+		return ErrInvalidPlayerName
+	}
+	if req.Level < 0 || req.Level > 4 {
+		return fmt.Errorf("%w: op level must be between 1 and 4", ErrInvalidListEntry)
+	}
+	return nil
+}
+
+func playerListCommand(list string, add bool, req PlayerListRequest) string {
+	switch list {
+	case ListWhitelist:
+		if add {
+			return "whitelist add " + req.Name
+		}
+		return "whitelist remove " + req.Name
+	case ListOps:
+		if add {
+			return "op " + req.Name
+		}
+		return "deop " + req.Name
+	case ListBannedPlayers:
+		if add {
+			return strings.TrimSpace("ban " + req.Name + " " + req.Reason)
+		}
+		return "pardon " + req.Name
+	default:
+		if add {
+			return strings.TrimSpace("ban-ip " + req.IP + " " + req.Reason)
+		}
+		return "pardon-ip " + req.IP
+	}
+}
+
+// sameEntry banned-ips 比 IP，其他比 UUID 或名稱
+func sameEntry(list string, e PlayerListEntry, req PlayerListRequest, uuid string) bool {
+	if list == ListBannedIPs {
+		return e.IP == req.IP
+	}
+	if uuid != "" && strings.EqualFold(e.UUID, uuid) {
+		return true
+	}
+	return req.Name != "" && strings.EqualFold(e.Name, req.Name)
+}
+
+// runningServer 伺服器開著就回傳它
+func (sm *ServerManager) runningServer(sid string) *Server {
+	sm.mu.RLock()
+	srv, exists := sm.servers[sid]
+	sm.mu.RUnlock()
+	if exists && srv.IsAlive() {
+		return srv
+	}
+	return nil
+}
+
+func (sm *ServerManager) AddPlayerListEntry(sid, workDir, list string, req PlayerListRequest) (*PlayerListResult, error) {
+	path, err := playerListPath(workDir, list)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePlayerListRequest(list, &req); err != nil {
+		return nil, err
+	}
+
+	if srv := sm.runningServer(sid); srv != nil {
+		if list == ListOps && (req.Level != 0 || req.BypassesPlayerLimit) {
+			return nil, ErrOpLevelRunning
+		}
+		out, err := srv.RunCommand(playerListCommand(list, true, req))
+		if err != nil {
+			return nil, err
+		}
+		return &PlayerListResult{Via: "command", Output: out.Output}, nil
+	}
+
+	entry := PlayerListEntry{IP: req.IP}
+	if list != ListBannedIPs {
+		if req.UUID != "" {
+			entry.Name, entry.UUID = req.Name, formatUUID(req.UUID)
+		} else if entry.Name, entry.UUID, err = resolvePlayer(workDir, req.Name); err != nil {
+			return nil, err
+		}
+	}
+	switch list {
+	case ListOps:
+		entry.Level = req.Level
+		if entry.Level == 0 {
+			entry.Level = 4
+			if props, err := readProperties(workDir); err == nil {
+				fmt.Sscanf(props["op-permission-level"], "%d", &entry.Level)
+			}
+		}
+		bypass := req.BypassesPlayerLimit
+		entry.BypassesPlayerLimit = &bypass
+	case ListBannedPlayers, ListBannedIPs:
+		entry.Created = time.Now().Format(playerListTimeLayout)
+		entry.Source = "Server"
+		entry.Expires = "forever"
+		entry.Reason = req.Reason
+		if entry.Reason == "" {
+			entry.Reason = "Banned by an operator."
+		}
+	}
+
+	unlock := playerListLocks.lock(workDir)
+	defer unlock()
+	entries, err := readPlayerList(path)
+	if err != nil {
+		return nil, err
+	}
+	// 已經在名單裡就更新
+	replaced := false
+	for i, e := range entries {
+		if sameEntry(list, e, PlayerListRequest{Name: entry.Name, IP: entry.IP}, entry.UUID) {
+			entries[i] = entry
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		entries = append(entries, entry)
+	}
+	if err := writePlayerList(path, entries); err != nil {
+		return nil, err
+	}
+	return &PlayerListResult{Via: "file", Entry: &entry}, nil
+}
+
+func (sm *ServerManager) RemovePlayerListEntry(sid, workDir, list string, req PlayerListRequest) (*PlayerListResult, error) {
+	path, err := playerListPath(workDir, list)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePlayerListRequest(list, &req); err != nil {
+		return nil, err
+	}
+
+	if srv := sm.runningServer(sid); srv != nil {
+		out, err := srv.RunCommand(playerListCommand(list, false, req))
+		if err != nil {
+			return nil, err
+		}
+		return &PlayerListResult{Via: "command", Output: out.Output}, nil
+	}
+
+	uuid := ""
+	if req.UUID != "" {
+		uuid = formatUUID(req.UUID)
+	}
+	unlock := playerListLocks.lock(workDir)
+	defer unlock()
+	entries, err := readPlayerList(path)
+	if err != nil {
+		return nil, err
+	}
+	kept := entries[:0]
+	var removed *PlayerListEntry
+	for _, e := range entries {
+		if removed == nil && sameEntry(list, e, req, uuid) {
+			e := e
+			removed = &e
+			continue
+		}
+		kept = append(kept, e)
+	}
+	if removed == nil {
+		return nil, ErrEntryNotFound
+	}
+	if err := writePlayerList(path, kept); err != nil {
+		return nil, err
+	}
+	return &PlayerListResult{Via: "file", Entry: removed}, nil
+}
@@ -0,0 +1,20 @@
+package service
+
+import "testing"
+
+func TestOfflinePlayerUUID(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Notch", "b50ad385-829d-3141-a216-7e7d7539ba7f"},
+		{"jeb_", "a762f560-4fce-3236-812a-b80efff0b62b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OfflinePlayerUUID(tt.name); got != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+// service/portPool.go
+// port 分配寫進 DB，後端重啟後知道哪些 port 被誰拿走；管理員可以手動保留或釋放 port
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"go-backend/model"
+	"sort"
+	"strconv"
+)
+
+var ErrPortNotInPool = errors.New("port is not in the configured port ranges")
+var ErrPortInUse = errors.New("port is in use")
+var ErrPortNotAllocated = errors.New("port is not allocated")
+
+// PortPoolInfo 管理員查詢用
+type PortPoolInfo struct {
+	Total       int                             `json:"total"`
+	Free        []int                           `json:"free"`
+	Allocations []model.MinecraftPortAllocation `json:"allocations"`
+}
+
+// persistPortWithOutLock 呼叫時必須持有 sm.mu
+func (sm *ServerManager) persistPortWithOutLock(port int, sid, kind string) {
+	err := model.SavePortAllocation(&model.MinecraftPortAllocation{Port: port, ServerID: sid, Kind: kind, Note: sm.reserved[port]})
+	if err != nil {
+		common.SysError("save port allocation error: " + err.Error())
+	}
+}
+
+// loadReservedPorts 先把保留的 port 拿掉，recover 才不會把它分出去；回傳全部紀錄給 dropStaleAllocations
+func (sm *ServerManager) loadReservedPorts() []model.MinecraftPortAllocation {
+	allocs, err := model.GetAllPortAllocations()
+	if err != nil {
+		common.SysError("load port allocations error: " + err.Error())
+		return nil
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	for _, a := range allocs {
+		if a.Kind != model.PortKindReserved {
+			continue
+		}
+		sm.reserved[a.Port] = a.Note
+		sm.takePort(a.Port, "", model.PortKindReserved)
+	}
+	return allocs
+}
+
+// dropStaleAllocations recover 沒有接回來的伺服器，它們的 port 紀錄已經沒用了
+func (sm *ServerManager) dropStaleAllocations(allocs []model.MinecraftPortAllocation) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	for _, a := range allocs {
+		if a.Kind == model.PortKindReserved {
+			continue
+		}
+		if sid, used := sm.usingPorts[a.Port]; used && sid == a.ServerID {
+			continue
+		}
+		common.SysLog(fmt.Sprintf("drop stale port allocation %d (%s)", a.Port, a.ServerID))
+		if err := model.RemovePortAllocation(a.Port); err != nil {
+			common.SysError("remove port allocation error: " + err.Error())
+		}
+	}
+}
+
+func (sm *ServerManager) ListPorts() (PortPoolInfo, error) {
+	allocs, err := model.GetAllPortAllocations()
+	if err != nil {
+		return PortPoolInfo{}, err
+	}
+	sm.mu.RLock()
+	free := make([]int, len(sm.availablePorts))
+	copy(free, sm.availablePorts)
+	total := len(sm.pool)
+	sm.mu.RUnlock()
+	sort.Ints(free)
+	return PortPoolInfo{Total: total, Free: free, Allocations: allocs}, nil
+}
+
+// ReservePort 只能保留目前沒有被使用的 port
+func (sm *ServerManager) ReservePort(port int, note string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if !sm.pool[port] {
+		return ErrPortNotInPool
+	}
+	if _, used := sm.usingPorts[port]; used {
+		return ErrPortInUse
+	}
+	sm.reserved[port] = note
+	sm.takePort(port, "", model.PortKindReserved)
+	return nil
+}
+
+// ReleasePort 釋放保留的 port；分給伺服器的 port 只有在伺服器沒在跑時才能釋放 (連同它的其他 port 一起)
+func (sm *ServerManager) ReleasePort(port int) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sid, used := sm.usingPorts[port]
+	if !used {
+		return ErrPortNotAllocated
+	}
+	if _, ok := sm.reserved[port]; ok {
+		delete(sm.reserved, port)
+		sm.releasePortWithOutLock(strconv.Itoa(port))
+		return nil
+	}
+	srv, exists := sm.servers[sid]
+	if !exists {
+		sm.releasePortWithOutLock(strconv.Itoa(port))
+		return nil
+	}
+	if !srv.idle() {
+		return ErrPortInUse
+	}
+	sm.releaseServerPortsWithOutLock(srv)
+	delete(sm.servers, sid)
+	return nil
+}
@@ -0,0 +1,57 @@
+//go:build !windows
+
+// service/process_unix.go
+
+package service
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// setProcAttr 讓 java 跑在自己的 process group，後端被 Ctrl+C 或重啟時不會連帶被殺
+func setProcAttr(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
+
+// isServerProcess 確認 pid 沒有被別的程式重複使用：cmdline 要有 server.jar，cwd 要是 workDir
+// 沒有 /proc 的系統只能相信 pid
+func isServerProcess(pid int, workDir string) bool {
+	procDir := filepath.Join("/proc", strconv.Itoa(pid))
+	cmdline, err := os.ReadFile(filepath.Join(procDir, "cmdline"))
+	if err != nil {
+		return processAlive(pid)
+	}
+	if !strings.Contains(string(cmdline), "server.jar") {
+		return false
+	}
+	cwd, err := os.Readlink(filepath.Join(procDir, "cwd"))
+	if err != nil {
+		return true
+	}
+	abs, err := filepath.Abs(workDir)
+	if err != nil {
+		return true
+	}
+	return filepath.Clean(cwd) == filepath.Clean(abs)
+}
+
+// terminateProcess 送 SIGTERM，Minecraft 的 shutdown hook 會存檔後結束
+func terminateProcess(p *os.Process) error {
+	return p.Signal(syscall.SIGTERM)
+}
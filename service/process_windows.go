@@ -0,0 +1,34 @@
+//go:build windows
+
+// service/process_windows.go
+
+package service
+
+import (
+	"os"
+	"os/exec"
+)
+
+func setProcAttr(cmd *exec.Cmd) {}
+
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	// windows 上 FindProcess 找不到就會回 error
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
+
+func isServerProcess(pid int, workDir string) bool {
+	return processAlive(pid)
+}
+
+// windows 沒有 SIGTERM，只能直接結束
+func terminateProcess(p *os.Process) error {
+	return p.Kill()
+}
@@ -0,0 +1,193 @@
+// service/propertyHistory.go
+// server.properties 的修改紀錄：每次透過面板修改都存一版完整內容，可以比對任兩版或還原
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"go-backend/model"
+	"os"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// PropertyDiffLine Op 是 "-" 或 "+"
+type PropertyDiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// 同一台伺服器的修改要排隊，紀錄才會跟檔案內容對得上
+var propertyLocks keyLocks
+
+// propertyLines 比對時忽略註解和空行，伺服器每次啟動都會改寫開頭的時間註解
+func propertyLines(content string) []string {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// diffPropertyLines 用 LCS 找出刪除與新增的行，properties 檔很小不用考慮效能
+func diffPropertyLines(a, b []string) []PropertyDiffLine {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	diff := []PropertyDiffLine{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i, j = i+1, j+1
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			diff = append(diff, PropertyDiffLine{Op: "-", Text: a[i]})
+			i++
+		default:
+			diff = append(diff, PropertyDiffLine{Op: "+", Text: b[j]})
+			j++
+		}
+	}
+	return diff
+}
+
+func formatPropertyDiff(diff []PropertyDiffLine) string {
+	var b strings.Builder
+	for _, d := range diff {
+		b.WriteString(d.Op + d.Text + "\n")
+	}
+	return b.String()
+}
+
+func readPropertyText(workDir string) (string, error) {
+	data, err := os.ReadFile(workDir + "/server.properties")
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return string(data), err
+}
+
+// recordPropertyRevision 跟最新一版 (忽略註解) 一樣就不記
+func recordPropertyRevision(sid, content string, author uint, source string, rollbackOf uint) error {
+	var prev []string
+	latest, err := model.GetLatestPropertyRevision(sid)
+	switch {
+	case err == nil:
+		prev = propertyLines(latest.Content)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// 第一筆是修改前的原始內容
+		if source == "external" {
+			source = "initial"
+		}
+	default:
+		return err
+	}
+	diff := diffPropertyLines(prev, propertyLines(content))
+	if latest != nil && len(diff) == 0 {
+		return nil
+	}
+	err = model.AddPropertyRevision(&model.MinecraftPropertyRevision{
+		ServerID:   sid,
+		AuthorID:   author,
+		Source:     source,
+		RollbackOf: rollbackOf,
+		Content:    content,
+		Diff:       formatPropertyDiff(diff),
+	})
+	if err != nil {
+		return err
+	}
+	return model.PrunePropertyRevisions(sid, common.PropertyHistoryKeep)
+}
+
+// editProperties 修改前先把面板以外的改動 (伺服器補上預設值、檔案管理) 記成一版，修改後再記一版
+// 紀錄失敗不影響修改本身，只留 log
+func editProperties(sid, workDir string, author uint, source string, rollbackOf uint, apply func() error) error {
+	unlock := propertyLocks.lock(sid)
+	defer unlock()
+
+	if before, err := readPropertyText(workDir); err == nil {
+		if err := recordPropertyRevision(sid, before, 0, "external", 0); err != nil {
+			common.SysError(fmt.Sprintf("record property revision for %s error: %s", sid, err.Error()))
+		}
+	}
+	if err := apply(); err != nil {
+		return err
+	}
+	after, err := readPropertyText(workDir)
+	if err == nil {
+		err = recordPropertyRevision(sid, after, author, source, rollbackOf)
+	}
+	if err != nil {
+		common.SysError(fmt.Sprintf("record property revision for %s error: %s", sid, err.Error()))
+	}
+	return nil
+}
+
+// ReplaceProperties 整個檔案換成 texts
+func ReplaceProperties(sid, workDir, texts string, author uint) error {
+	return editProperties(sid, workDir, author, "upload", 0, func() error {
+		return ReplaceProperty(workDir, texts)
+	})
+}
+
+func ListPropertyRevisions(sid string) ([]model.MinecraftPropertyRevision, error) {
+	return model.GetPropertyRevisions(sid, common.PropertyHistoryKeep)
+}
+
+func GetPropertyRevision(sid string, id uint) (*model.MinecraftPropertyRevision, error) {
+	rev, err := model.GetPropertyRevision(sid, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRevisionNotFound
+	}
+	return rev, err
+}
+
+// DiffPropertyRevisions from 到 to 的差異，兩版都要屬於這台伺服器
+func DiffPropertyRevisions(sid string, from, to uint) ([]PropertyDiffLine, error) {
+	a, err := GetPropertyRevision(sid, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := GetPropertyRevision(sid, to)
+	if err != nil {
+		return nil, err
+	}
+	return diffPropertyLines(propertyLines(a.Content), propertyLines(b.Content)), nil
+}
+
+// RollbackProperties 跟 Server.SetProperty 一樣，伺服器開著時不能改
+func (sm *ServerManager) RollbackProperties(sid, workDir string, id, author uint) error {
+	if sm.isAlive(sid) {
+		return ErrServerRunning
+	}
+	rev, err := GetPropertyRevision(sid, id)
+	if err != nil {
+		return err
+	}
+	return editProperties(sid, workDir, author, "rollback", rev.ID, func() error {
+		if sm.isAlive(sid) {
+			return ErrServerRunning
+		}
+		return ReplaceProperty(workDir, rev.Content)
+	})
+}
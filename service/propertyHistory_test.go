@@ -0,0 +1,37 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffPropertyLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want []PropertyDiffLine
+	}{
+		{"same", []string{"a=1", "b=2"}, []string{"a=1", "b=2"}, []PropertyDiffLine{}},
+		{"changed value", []string{"a=1", "b=2", "c=3"}, []string{"a=1", "b=5", "c=3"},
+			[]PropertyDiffLine{{Op: "-", Text: "b=2"}, {Op: "+", Text: "b=5"}}},
+		{"added", []string{"a=1"}, []string{"a=1", "b=2"}, []PropertyDiffLine{{Op: "+", Text: "b=2"}}},
+		{"removed", []string{"a=1", "b=2"}, []string{"b=2"}, []PropertyDiffLine{{Op: "-", Text: "a=1"}}},
+		{"from empty", nil, []string{"a=1"}, []PropertyDiffLine{{Op: "+", Text: "a=1"}}},
+		{"to empty", []string{"a=1"}, nil, []PropertyDiffLine{{Op: "-", Text: "a=1"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffPropertyLines(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPropertyLinesIgnoresComments(t *testing.T) {
+	got := propertyLines("#Minecraft server properties\n#Sat Jan 01 00:00:00 UTC 2000\n\nmotd=hi\r\n! note\nlevel-name=world\n")
+	want := []string{"motd=hi", "level-name=world"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
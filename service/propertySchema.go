@@ -0,0 +1,470 @@
+// service/propertySchema.go
+// server.properties 的欄位定義：型別、範圍、預設值、改了要不要重開，讀寫時都照這份驗證
+
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type PropertyType string
+
+const (
+	PropBool   PropertyType = "bool"
+	PropInt    PropertyType = "int"
+	PropString PropertyType = "string"
+	PropEnum   PropertyType = "enum"
+)
+
+const propertyStringMaxLen = 4096
+
+// PropertySpec 一個 key 的定義
+// Managed 是面板啟動時自己寫入 (port、rcon) 的 key，使用者改了也會被蓋掉，所以不開放修改
+// Live 不為 nil 的 key 伺服器開著時可以直接用指令套用，不用重開
+type PropertySpec struct {
+	Key     string       `json:"key"`
+	Type    PropertyType `json:"type"`
+	Default string       `json:"default"`
+	Min     *int64       `json:"min,omitempty"`
+	Max     *int64       `json:"max,omitempty"`
+	Enum    []string     `json:"enum,omitempty"`
+	Restart bool         `json:"restart_required"`
+	Managed bool         `json:"managed"`
+
+	Live  func(value string) string `json:"-"`
+	Check func(value string) error  `json:"-"`
+}
+
+// PropertyValue GET 回傳的一筆，Value 是轉好型別的值，格式錯誤時是 nil 並附上 Error
+type PropertyValue struct {
+	PropertySpec
+	Value any    `json:"value"`
+	Raw   string `json:"raw"`
+	Set   bool   `json:"set"` // 檔案裡有沒有這個 key
+	Error string `json:"error,omitempty"`
+}
+
+// PropertyValidationError 每個 key 的錯誤訊息
+type PropertyValidationError struct {
+	Errors map[string]string
+}
+
+func (e *PropertyValidationError) Error() string {
+	keys := make([]string, 0, len(e.Errors))
+	for k := range e.Errors {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	msgs := make([]string, len(keys))
+	for i, k := range keys {
+		msgs[i] = k + ": " + e.Errors[k]
+	}
+	return "invalid properties: " + strings.Join(msgs, "; ")
+}
+
+func rng(min, max int64) (*int64, *int64) { return &min, &max }
+
+func boolProp(key, def string) PropertySpec {
+	return PropertySpec{Key: key, Type: PropBool, Default: def, Restart: true}
+}
+
+func intProp(key, def string, min, max int64) PropertySpec {
+	lo, hi := rng(min, max)
+	return PropertySpec{Key: key, Type: PropInt, Default: def, Min: lo, Max: hi, Restart: true}
+}
+
+func stringProp(key, def string) PropertySpec {
+	return PropertySpec{Key: key, Type: PropString, Default: def, Restart: true}
+}
+
+func enumProp(key, def string, values ...string) PropertySpec {
+	return PropertySpec{Key: key, Type: PropEnum, Default: def, Enum: values, Restart: true}
+}
+
+func managed(p PropertySpec) PropertySpec {
+	p.Managed = true
+	return p
+}
+
+func live(p PropertySpec, cmd func(string) string) PropertySpec {
+	p.Restart = false
+	p.Live = cmd
+	return p
+}
+
+func checked(p PropertySpec, check func(string) error) PropertySpec {
+	p.Check = check
+	return p
+}
+
+// 伺服器或面板自己用的資料夾，不能拿來當世界，不然備份、匯入會把它們當成世界處理
+var reservedLevelNames = map[string]bool{
+	backupDirName:   true,
+	consoleLogDir:   true,
+	"logs":          true,
+	"mods":          true,
+	"config":        true,
+	"libraries":     true,
+	"versions":      true,
+	"crash-reports": true,
+}
+
+// checkLevelName 世界資料夾必須在伺服器目錄裡
+func checkLevelName(v string) error {
+	if v == "" || v == "." || v == ".." || strings.ContainsAny(v, "/\\:") {
+		return fmt.Errorf("must be a plain folder name")
+	}
+	if reservedLevelNames[v] {
+		return fmt.Errorf("%s is reserved", v)
+	}
+	return nil
+}
+
+// 原版伺服器的 key，新版本才有的 key 舊版本會忽略，不影響
+var propertySchema = map[string]PropertySpec{}
+
+func init() {
+	specs := []PropertySpec{
+		boolProp("accepts-transfers", "false"),
+		boolProp("allow-flight", "false"),
+		boolProp("allow-nether", "true"),
+		boolProp("broadcast-console-to-ops", "true"),
+		managed(boolProp("broadcast-rcon-to-ops", "true")),
+		stringProp("bug-report-link", ""),
+		live(enumProp("difficulty", "easy", "peaceful", "easy", "normal", "hard"),
+			func(v string) string { return "difficulty " + v }),
+		boolProp("enable-command-block", "false"),
+		boolProp("enable-jmx-monitoring", "false"),
+		// query 要另外一個 port，面板沒有分配
+		managed(boolProp("enable-query", "false")),
+		managed(boolProp("enable-rcon", "false")),
+		boolProp("enable-status", "true"),
+		boolProp("enforce-secure-profile", "true"),
+		boolProp("enforce-whitelist", "false"),
+		intProp("entity-broadcast-range-percentage", "100", 10, 1000),
+		boolProp("force-gamemode", "false"),
+		intProp("function-permission-level", "2", 1, 4),
+		live(enumProp("gamemode", "survival", "survival", "creative", "adventure", "spectator"),
+			func(v string) string { return "defaultgamemode " + v }),
+		boolProp("generate-structures", "true"),
+		stringProp("generator-settings", "{}"),
+		boolProp("hardcore", "false"),
+		boolProp("hide-online-players", "false"),
+		stringProp("initial-disabled-packs", ""),
+		stringProp("initial-enabled-packs", "vanilla"),
+		checked(stringProp("level-name", "world"), checkLevelName),
+		stringProp("level-seed", ""),
+		stringProp("level-type", "minecraft:normal"),
+		boolProp("log-ips", "true"),
+		intProp("max-chained-neighbor-updates", "1000000", math.MinInt32, math.MaxInt32),
+		intProp("max-players", "20", 0, math.MaxInt32),
+		intProp("max-tick-time", "60000", -1, math.MaxInt64),
+		intProp("max-world-size", "29999984", 1, 29999984),
+		stringProp("motd", "A Minecraft Server"),
+		intProp("network-compression-threshold", "256", -1, math.MaxInt32),
+		boolProp("online-mode", "true"),
+		intProp("op-permission-level", "4", 0, 4),
+		intProp("player-idle-timeout", "0", 0, math.MaxInt32),
+		boolProp("prevent-proxy-connections", "false"),
+		boolProp("pvp", "true"),
+		managed(intProp("query.port", "25565", 1, 65535)),
+		intProp("rate-limit", "0", 0, math.MaxInt32),
+		managed(stringProp("rcon.password", "")),
+		managed(intProp("rcon.port", "25575", 1, 65535)),
+		enumProp("region-file-compression", "deflate", "deflate", "lz4", "none"),
+		boolProp("require-resource-pack", "false"),
+		stringProp("resource-pack", ""),
+		stringProp("resource-pack-id", ""),
+		stringProp("resource-pack-prompt", ""),
+		stringProp("resource-pack-sha1", ""),
+		// 綁定的位址與 port 由面板決定 (啟動時帶 --port)
+		managed(stringProp("server-ip", "")),
+		managed(intProp("server-port", "25565", 1, 65535)),
+		intProp("simulation-distance", "10", 3, 32),
+		boolProp("spawn-animals", "true"),
+		boolProp("spawn-monsters", "true"),
+		boolProp("spawn-npcs", "true"),
+		intProp("spawn-protection", "16", 0, math.MaxInt32),
+		boolProp("sync-chunk-writes", "true"),
+		stringProp("text-filtering-config", ""),
+		boolProp("use-native-transport", "true"),
+		intProp("view-distance", "10", 3, 32),
+		live(boolProp("white-list", "false"), func(v string) string {
+			if v == "true" {
+				return "whitelist on"
+			}
+			return "whitelist off"
+		}),
+	}
+	for _, spec := range specs {
+		propertySchema[spec.Key] = spec
+	}
+}
+
+// PropertySchema 依 key 排序
+func PropertySchema() []PropertySpec {
+	specs := make([]PropertySpec, 0, len(propertySchema))
+	for _, spec := range propertySchema {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Key < specs[j].Key })
+	return specs
+}
+
+// unescapeProperty 處理 java Properties 的跳脫 (\uXXXX、\:、\= 等)
+func unescapeProperty(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+4 < len(s) {
+				if r, err := strconv.ParseUint(s[i+1:i+5], 16, 16); err == nil {
+					i += 4
+					// emoji 之類的會拆成兩個 \u
+					if utf16.IsSurrogate(rune(r)) && i+6 < len(s) && s[i+1:i+3] == "\\u" {
+						if r2, err := strconv.ParseUint(s[i+3:i+7], 16, 16); err == nil {
+							if dr := utf16.DecodeRune(rune(r), rune(r2)); dr != utf8.RuneError {
+								b.WriteRune(dr)
+								i += 6
+								continue
+							}
+						}
+					}
+					b.WriteRune(rune(r))
+					continue
+				}
+			}
+			b.WriteByte('u')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// escapeProperty 寫回檔案前跳脫，非 ASCII 轉成 \uXXXX 跟 java 寫出來的一樣
+func escapeProperty(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == ':' || r == '=' || r == '#' || r == '!':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			if r1, r2 := utf16.EncodeRune(r); r1 != utf8.RuneError {
+				fmt.Fprintf(&b, `\u%04X\u%04X`, r1, r2)
+			} else {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// parsePropertyValue 檔案裡的字串轉成對應型別
+func parsePropertyValue(spec PropertySpec, raw string) (any, error) {
+	switch spec.Type {
+	case PropBool:
+		switch strings.ToLower(raw) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return nil, fmt.Errorf("expected true or false")
+	case PropInt:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected an integer")
+		}
+		if (spec.Min != nil && n < *spec.Min) || (spec.Max != nil && n > *spec.Max) {
+			return nil, fmt.Errorf("must be between %d and %d", *spec.Min, *spec.Max)
+		}
+		return n, nil
+	case PropEnum:
+		v := strings.ToLower(raw)
+		for _, e := range spec.Enum {
+			if v == e {
+				return v, nil
+			}
+		}
+		// 舊版的 difficulty / gamemode 用數字
+		if n, err := strconv.Atoi(raw); err == nil && n >= 0 && n < len(spec.Enum) && (spec.Key == "difficulty" || spec.Key == "gamemode") {
+			return spec.Enum[n], nil
+		}
+		return nil, fmt.Errorf("must be one of %s", strings.Join(spec.Enum, ", "))
+	default:
+		s := unescapeProperty(raw)
+		if len(s) > propertyStringMaxLen {
+			return nil, fmt.Errorf("longer than %d characters", propertyStringMaxLen)
+		}
+		return s, nil
+	}
+}
+
+// normalizePropertyInput JSON 送來的值 (bool、數字或字串) 驗證後轉成要寫進檔案的字串
+func normalizePropertyInput(spec PropertySpec, in any) (string, error) {
+	var raw string
+	switch v := in.(type) {
+	case bool:
+		raw = strconv.FormatBool(v)
+	case float64:
+		if v != math.Trunc(v) {
+			return "", fmt.Errorf("expected an integer")
+		}
+		raw = strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		raw = v
+	default:
+		return "", fmt.Errorf("unsupported value type %T", in)
+	}
+	if spec.Type == PropString {
+		if strings.ContainsRune(raw, 0) || len(raw) > propertyStringMaxLen {
+			return "", fmt.Errorf("invalid string")
+		}
+		if spec.Check != nil {
+			if err := spec.Check(raw); err != nil {
+				return "", err
+			}
+		}
+		return escapeProperty(raw), nil
+	}
+	raw = strings.TrimSpace(raw)
+	val, err := parsePropertyValue(spec, raw)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprint(val), nil
+}
+
+// TypedProperties 已知的 key 依 schema 轉型 (沒設定的用預設值)，不認得的 key 原樣放在 extra
+func TypedProperties(workDir string) ([]PropertyValue, map[string]string, error) {
+	props, err := readProperties(workDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	values := []PropertyValue{}
+	for _, spec := range PropertySchema() {
+		raw, set := props[spec.Key]
+		pv := PropertyValue{PropertySpec: spec, Raw: raw, Set: set}
+		if !set {
+			raw = spec.Default
+		}
+		if v, err := parsePropertyValue(spec, raw); err != nil {
+			pv.Error = err.Error()
+		} else {
+			pv.Value = v
+		}
+		values = append(values, pv)
+	}
+	extra := map[string]string{}
+	for k, v := range props {
+		if _, ok := propertySchema[k]; !ok {
+			extra[k] = v
+		}
+	}
+	return values, extra, nil
+}
+
+// ValidatePropertyPatch 全部通過才回傳要寫入的值，不認得的 key 和面板管理的 key 都不接受
+func ValidatePropertyPatch(patch map[string]any) (map[string]string, error) {
+	errs := map[string]string{}
+	out := map[string]string{}
+	for key, in := range patch {
+		spec, ok := propertySchema[key]
+		switch {
+		case !ok:
+			errs[key] = "unknown property"
+		case spec.Managed:
+			errs[key] = "managed by the panel"
+		default:
+			v, err := normalizePropertyInput(spec, in)
+			if err != nil {
+				errs[key] = err.Error()
+				continue
+			}
+			out[key] = v
+		}
+	}
+	if len(errs) > 0 {
+		return nil, &PropertyValidationError{Errors: errs}
+	}
+	return out, nil
+}
+
+// PropertyPatchResult Applied 是伺服器開著時直接用指令套用的 key，Restart 是要重開才會生效的 key
+type PropertyPatchResult struct {
+	Updated []string `json:"updated"`
+	Applied []string `json:"applied"`
+	Restart []string `json:"restart_required"`
+}
+
+// PatchProperties 驗證後寫入並記錄修改者；伺服器開著時能用指令套用的就直接套用
+func (sm *ServerManager) PatchProperties(sid, workDir string, patch map[string]any, author uint) (*PropertyPatchResult, error) {
+	values, err := ValidatePropertyPatch(patch)
+	if err != nil {
+		return nil, err
+	}
+	err = editProperties(sid, workDir, author, "patch", 0, func() error {
+		return UpdateProperties(workDir, values)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PropertyPatchResult{Updated: []string{}, Applied: []string{}, Restart: []string{}}
+	for key := range values {
+		result.Updated = append(result.Updated, key)
+	}
+	sort.Strings(result.Updated)
+
+	sm.mu.RLock()
+	srv, exists := sm.servers[sid]
+	sm.mu.RUnlock()
+	if !exists || !srv.IsAlive() {
+		return result, nil
+	}
+	for _, key := range result.Updated {
+		spec := propertySchema[key]
+		if spec.Live != nil {
+			if _, err := srv.RunCommand(spec.Live(values[key])); err == nil {
+				result.Applied = append(result.Applied, key)
+				continue
+			}
+		}
+		result.Restart = append(result.Restart, key)
+	}
+	return result, nil
+}
@@ -0,0 +1,245 @@
+// service/rcon.go
+// Source RCON 協定的 client，Minecraft 的 rcon 用的就是這個
+
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	rconTypeResponse = 0
+	rconTypeCommand  = 2
+	rconTypeAuth     = 3
+
+	rconMaxPayload = 4096
+	rconTimeout    = 5 * time.Second
+)
+
+var ErrRconAuth = errors.New("rcon authentication failed")
+
+type rconClient struct {
+	conn   net.Conn
+	mu     sync.Mutex
+	nextID int32
+}
+
+func dialRcon(addr, password string, timeout time.Duration) (*rconClient, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &rconClient{conn: conn}
+	if err := c.auth(password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *rconClient) auth(password string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.conn.SetDeadline(time.Now().Add(rconTimeout))
+	id := c.newID()
+	if err := c.writePacket(id, rconTypeAuth, password); err != nil {
+		return err
+	}
+	for {
+		respID, typ, _, err := c.readPacket()
+		if err != nil {
+			return err
+		}
+		// 有些實作會先回一個空的 response，再回 auth 結果
+		if typ != rconTypeCommand {
+			continue
+		}
+		if respID == -1 {
+			return ErrRconAuth
+		}
+		if respID == id {
+			return nil
+		}
+	}
+}
+
+// Execute 送出指令並回傳輸出
+// 回應可能被切成好幾個封包，所以後面再送一個不存在的 type 當結尾標記，收到它的回應就代表指令輸出結束
+func (c *rconClient) Execute(command string, timeout time.Duration) (string, error) {
+	if len(command) > rconMaxPayload-10 {
+		return "", fmt.Errorf("rcon command too long")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.conn.SetDeadline(time.Now().Add(timeout))
+
+	id := c.newID()
+	end := c.newID()
+	if err := c.writePacket(id, rconTypeCommand, command); err != nil {
+		return "", err
+	}
+	if err := c.writePacket(end, rconTypeResponse, ""); err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	for {
+		respID, _, body, err := c.readPacket()
+		if err != nil {
+			return "", err
+		}
+		if respID == end {
+			return out.String(), nil
+		}
+		if respID == id {
+			out.WriteString(body)
+		}
+	}
+}
+
+func (c *rconClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *rconClient) newID() int32 {
+	c.nextID++
+	if c.nextID <= 0 {
+		c.nextID = 1
+	}
+	return c.nextID
+}
+
+// 封包格式：int32 length | int32 id | int32 type | body | 0x00 0x00 (little endian)
+func (c *rconClient) writePacket(id, typ int32, body string) error {
+	var buf bytes.Buffer
+	length := int32(4 + 4 + len(body) + 2)
+	_ = binary.Write(&buf, binary.LittleEndian, length)
+	_ = binary.Write(&buf, binary.LittleEndian, id)
+	_ = binary.Write(&buf, binary.LittleEndian, typ)
+	buf.WriteString(body)
+	buf.Write([]byte{0, 0})
+	_, err := c.conn.Write(buf.Bytes())
+	return err
+}
+
+func (c *rconClient) readPacket() (int32, int32, string, error) {
+	var length int32
+	if err := binary.Read(c.conn, binary.LittleEndian, &length); err != nil {
+		return 0, 0, "", err
+	}
+	if length < 10 || length > rconMaxPayload+10 {
+		return 0, 0, "", fmt.Errorf("bad rcon packet length %d", length)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(c.conn, data); err != nil {
+		return 0, 0, "", err
+	}
+	id := int32(binary.LittleEndian.Uint32(data[0:4]))
+	typ := int32(binary.LittleEndian.Uint32(data[4:8]))
+	body := string(bytes.TrimRight(data[8:], "\x00"))
+	return id, typ, body, nil
+}
+
+// ---------------- Server ----------------
+
+// CommandResult via 是 "rcon" 或 "stdin"，走 stdin 拿不到輸出
+type CommandResult struct {
+	Output string `json:"output"`
+	Via    string `json:"via"`
+}
+
+// setRcon 設定 rcon port 與密碼，密碼空的話啟動時會產生
+func (s *Server) setRcon(portStr, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rconPort = portStr
+	s.rconPassword = password
+}
+
+// enableRconWithOutLock 啟動前寫進 server.properties，呼叫時必須持有 s.mu
+// 沒有分到 port 時要明確關掉，避免沿用舊的 rcon.port 撞到別台的 port
+func (s *Server) enableRconWithOutLock() error {
+	if s.rconPort == "" {
+		return UpdateProperties(s.workDir, map[string]string{"enable-rcon": "false"})
+	}
+	if s.rconPassword == "" {
+		pw, err := common.GetSecureRandomString(24)
+		if err != nil {
+			return err
+		}
+		s.rconPassword = pw
+	}
+	return UpdateProperties(s.workDir, map[string]string{
+		"enable-rcon":           "true",
+		"rcon.port":             s.rconPort,
+		"rcon.password":         s.rconPassword,
+		"broadcast-rcon-to-ops": "false",
+	})
+}
+
+// RunCommand 伺服器開好後走 rcon 拿輸出；rcon 連不上 (還在開機、被關掉) 就退回 stdin
+func (s *Server) RunCommand(cmd string) (CommandResult, error) {
+	return s.runCommand(cmd, rconTimeout)
+}
+
+// runCommand timeout 是等 rcon 回應的時間，save-all 這種要跑很久的指令要給長一點
+func (s *Server) runCommand(cmd string, timeout time.Duration) (CommandResult, error) {
+	s.mu.RLock()
+	state, port, password := s.state, s.rconPort, s.rconPassword
+	s.mu.RUnlock()
+	if !state.Alive() {
+		return CommandResult{}, errors.New("server not running")
+	}
+
+	if state == StateRunning && port != "" && password != "" {
+		client, err := s.rconClient(port, password)
+		if err == nil {
+			out, err := client.Execute(cmd, timeout)
+			if err != nil {
+				// 指令可能已經送出，不能再用 stdin 重送一次
+				s.closeRcon()
+				return CommandResult{}, err
+			}
+			return CommandResult{Output: out, Via: "rcon"}, nil
+		}
+		common.SysDebug(fmt.Sprintf("server %s rcon unavailable: %s", s.sid, err.Error()))
+	}
+
+	if err := s.SendCommand(cmd); err != nil {
+		return CommandResult{}, err
+	}
+	return CommandResult{Via: "stdin"}, nil
+}
+
+// rconClient 第一次用到才連線，之後重複使用同一條連線
+func (s *Server) rconClient(port, password string) (*rconClient, error) {
+	s.rconMu.Lock()
+	defer s.rconMu.Unlock()
+	if s.rcon != nil {
+		return s.rcon, nil
+	}
+	client, err := dialRcon(net.JoinHostPort("127.0.0.1", port), password, 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	s.rcon = client
+	return client, nil
+}
+
+func (s *Server) closeRcon() {
+	s.rconMu.Lock()
+	defer s.rconMu.Unlock()
+	if s.rcon != nil {
+		_ = s.rcon.Close()
+		s.rcon = nil
+	}
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// 伺服器一直沒回應時，Execute 要在給定的時間內逾時，而不是固定的 rconTimeout
+func TestRconExecuteTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go io.Copy(io.Discard, server)
+
+	c := &rconClient{conn: client}
+	start := time.Now()
+	_, err := c.Execute("save-all flush", 50*time.Millisecond)
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > rconTimeout {
+		t.Fatalf("Execute took %s, timeout was ignored", elapsed)
+	}
+}
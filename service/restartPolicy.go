@@ -0,0 +1,173 @@
+// service/restartPolicy.go
+// crash 偵測後依照每台伺服器的重啟策略自動重開，連續 crash 太多次就停用自動重啟
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"go-backend/model"
+	"time"
+)
+
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+// 重新接管的 process 不是自己的 child，拿不到 exit code
+const exitCodeUnknown = -255
+
+var ErrInvalidRestartPolicy = errors.New("invalid restart policy")
+
+type RestartPolicy struct {
+	Mode       string `json:"mode"`
+	MaxRetries int    `json:"max_retries"` // on-failure 連續自動重啟的上限
+}
+
+func ValidateRestartPolicy(p RestartPolicy) error {
+	switch p.Mode {
+	case RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidRestartPolicy, p.Mode)
+	}
+	if p.MaxRetries < 0 || p.MaxRetries > 100 {
+		return fmt.Errorf("%w: max retries must be between 0 and 100", ErrInvalidRestartPolicy)
+	}
+	return nil
+}
+
+type exitInfo struct {
+	code          int
+	at            time.Time
+	crashed       bool
+	stopRequested bool // 透過 Stop 結束的不算 crash
+}
+
+type restartState struct {
+	timer    *time.Timer
+	retries  int         // 連續自動重啟次數
+	failures []time.Time // 最近的 crash 時間，判斷 crash loop 用
+	disabled bool        // crash loop 後停用，直到下一次手動啟動
+}
+
+// resetRestartWithOutLock 手動啟動時呼叫，呼叫時必須持有 s.mu
+func (s *Server) resetRestartWithOutLock() {
+	s.cancelRestartWithOutLock()
+	s.restart.retries = 0
+	s.restart.failures = nil
+	s.restart.disabled = false
+}
+
+// cancelRestartWithOutLock 回傳是否真的取消了一個等待中的重啟
+func (s *Server) cancelRestartWithOutLock() bool {
+	if s.restart.timer == nil {
+		return false
+	}
+	s.restart.timer.Stop()
+	s.restart.timer = nil
+	return true
+}
+
+func (s *Server) recordExit(exitCode int, crashed, requested bool) {
+	if crashed {
+		common.SysError(fmt.Sprintf("Server: %s crashed, exit code: %d", s.sid, exitCode))
+	}
+	err := model.RecordServerExit(&model.MinecraftServerExit{
+		ServerID:  s.sid,
+		ExitCode:  exitCode,
+		Crashed:   crashed,
+		Requested: requested,
+	})
+	if err != nil {
+		common.SysError("record server exit error: " + err.Error())
+	}
+}
+
+// handleExit 決定要不要排程自動重啟
+func (s *Server) handleExit(crashed, requested bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if requested || s.restart.disabled {
+		return
+	}
+	policy := s.launch.Restart
+	switch policy.Mode {
+	case RestartAlways:
+	case RestartOnFailure:
+		if !crashed {
+			return
+		}
+	default:
+		return
+	}
+
+	now := time.Now()
+	window := time.Duration(common.CrashLoopWindow) * time.Second
+	// 穩定跑超過一個 window 就重新計算
+	if now.Sub(s.startedAt) > window {
+		s.restart.retries = 0
+	}
+
+	if crashed {
+		recent := s.restart.failures[:0]
+		for _, t := range s.restart.failures {
+			if now.Sub(t) <= window {
+				recent = append(recent, t)
+			}
+		}
+		s.restart.failures = append(recent, now)
+		if len(s.restart.failures) >= common.CrashLoopMaxFailures {
+			s.restart.disabled = true
+			msg := fmt.Sprintf("Server: %s crashed %d times in %s, auto restart disabled", s.sid, len(s.restart.failures), window)
+			common.SysError(msg)
+			go func() { _ = common.SendErrorToDc(msg) }()
+			return
+		}
+	}
+
+	if policy.Mode == RestartOnFailure && s.restart.retries >= policy.MaxRetries {
+		common.SysLog(fmt.Sprintf("Server: %s reached max retries (%d), not restarting", s.sid, policy.MaxRetries))
+		return
+	}
+
+	delay := restartBackoff(s.restart.retries)
+	s.restart.retries++
+	common.SysLog(fmt.Sprintf("Server: %s will restart in %s (attempt %d)", s.sid, delay, s.restart.retries))
+	s.restart.timer = time.AfterFunc(delay, s.autoRestart)
+}
+
+// restartBackoff base * 2^retries，上限 RestartBackoffMax
+func restartBackoff(retries int) time.Duration {
+	base := time.Duration(common.RestartBackoffBase) * time.Second
+	max := time.Duration(common.RestartBackoffMax) * time.Second
+	d := base
+	for i := 0; i < retries && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+func (s *Server) autoRestart() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.restart.timer == nil {
+		// 已經被 Stop/Start 取消
+		return
+	}
+	s.restart.timer = nil
+	if s.state.Alive() {
+		return
+	}
+	if err := s.startWithOutLock(); err != nil {
+		common.SysError(fmt.Sprintf("Server: %s auto restart failed: %s", s.sid, err.Error()))
+		return
+	}
+	common.SysLog("Server auto restarted: " + s.sid)
+}
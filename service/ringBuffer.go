@@ -0,0 +1,78 @@
+// service/ringBuffer.go
+
+package service
+
+import "sync"
+
+// ringBuffer 固定大小的 console buffer，寫滿後覆蓋最舊的資料
+type ringBuffer struct {
+	mu   sync.Mutex
+	buf  []byte
+	pos  int // 下一個寫入位置
+	full bool
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	if size <= 0 {
+		size = 1024 * 64
+	}
+	return &ringBuffer{buf: make([]byte, size)}
+}
+
+func (r *ringBuffer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := len(p)
+	size := len(r.buf)
+	if n >= size {
+		copy(r.buf, p[n-size:])
+		r.pos = 0
+		r.full = true
+		return n, nil
+	}
+
+	c := copy(r.buf[r.pos:], p)
+	if c < n {
+		copy(r.buf, p[c:])
+	}
+	if r.pos+n >= size {
+		r.full = true
+	}
+	r.pos = (r.pos + n) % size
+	return n, nil
+}
+
+func (r *ringBuffer) WriteString(s string) (int, error) {
+	return r.Write([]byte(s))
+}
+
+// Bytes 依照時間順序回傳目前的內容 (複製一份)
+func (r *ringBuffer) Bytes() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		out := make([]byte, r.pos)
+		copy(out, r.buf[:r.pos])
+		return out
+	}
+	out := make([]byte, 0, len(r.buf))
+	out = append(out, r.buf[r.pos:]...)
+	out = append(out, r.buf[:r.pos]...)
+	return out
+}
+
+// Tail 回傳最後 n bytes
+func (r *ringBuffer) Tail(n int) string {
+	data := r.Bytes()
+	if len(data) > n {
+		data = data[len(data)-n:]
+	}
+	return string(data)
+}
+
+func (r *ringBuffer) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pos = 0
+	r.full = false
+}
@@ -0,0 +1,224 @@
+// service/serverClone.go
+// 複製伺服器與伺服器模板：可以選擇要帶走世界、設定檔、mods、server.properties
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"go-backend/model"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrCloneWhileRunning = errors.New("stop the server before copying its world")
+
+const serverIDAttempts = 20
+
+// CloneOptions 要複製哪些東西；server.jar、eula 和 Fabric 的 libraries 一定會帶
+type CloneOptions struct {
+	World      bool `json:"world"`
+	Config     bool `json:"config"` // config/ 與 ops/whitelist/banned 這類 JSON
+	Mods       bool `json:"mods"`
+	Properties bool `json:"properties"`
+}
+
+// 一定要有才跑得起來的檔案
+var cloneRuntimeEntries = []string{"server.jar", "eula.txt", "libraries", "versions", ".fabric"}
+
+var cloneConfigEntries = []string{"config", "ops.json", "whitelist.json", "banned-players.json", "banned-ips.json"}
+
+// 伺服器自己的身分，複製過去會跟原本的伺服器衝突
+var cloneResetProperties = map[string]string{
+	"enable-rcon":   "false",
+	"rcon.password": "",
+}
+
+// copyEntry 來源不存在就略過
+func copyEntry(src, dst string) error {
+	info, err := os.Stat(src)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return common.Copy(src, dst)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	return common.CopyFile(src, dst)
+}
+
+// copyServerFiles 依照 opts 把 src 的檔案複製到 dst (dst 必須是空的或不存在)
+func copyServerFiles(src, dst string, opts CloneOptions) error {
+	if existing, err := os.ReadDir(dst); err == nil && len(existing) > 0 {
+		return fmt.Errorf("%s is not empty", dst)
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	entries := append([]string{}, cloneRuntimeEntries...)
+	if opts.Config {
+		entries = append(entries, cloneConfigEntries...)
+	}
+	if opts.Mods {
+		entries = append(entries, "mods")
+	}
+	if opts.Properties {
+		entries = append(entries, "server.properties")
+	}
+
+	for _, name := range entries {
+		if err := copyEntry(filepath.Join(src, name), filepath.Join(dst, name)); err != nil {
+			return fmt.Errorf("copy %s: %w", name, err)
+		}
+	}
+
+	if opts.World {
+		// 沒帶 server.properties 的話新伺服器會用預設的 level-name，世界要改放到 world 底下才會被讀到
+		world, target := levelName(src), levelName(src)
+		if !opts.Properties {
+			target = "world"
+		}
+		for _, suffix := range []string{"", "_nether", "_the_end"} {
+			if err := copyEntry(filepath.Join(src, world+suffix), filepath.Join(dst, target+suffix)); err != nil {
+				return fmt.Errorf("copy %s: %w", world+suffix, err)
+			}
+		}
+		// 原本伺服器的 lock 檔帶過去會讓新伺服器開不起來
+		_ = os.Remove(filepath.Join(dst, target, "session.lock"))
+	}
+	if opts.Properties {
+		if err := UpdateProperties(dst, cloneResetProperties); err != nil {
+			return err
+		}
+		_ = os.Remove(filepath.Join(dst, "server.properties.bak"))
+	}
+	return nil
+}
+
+// newServerID 跟 CreateServer 一樣的格式：mcs[f|v]v-<版本>-<隨機>-OID-<owner>
+func newServerID(serverType, serverVer, ownerID string) (string, error) {
+	var prefix string
+	switch serverType {
+	case "Fabric":
+		prefix = "mcsfv-"
+	case "Vanilla":
+		prefix = "mcsvv-"
+	default:
+		return "", fmt.Errorf("unsupported server type: %s", serverType)
+	}
+	return prefix + serverVer + "-" + common.GetRandomIntString(4) + "-" + "OID-" + ownerID, nil
+}
+
+// newServerDir 產生還沒用過的 server ID 並建立它的目錄
+// 目錄用 os.Mkdir 建立，已經存在 (或資料庫裡有同樣的 ID) 就重新產生，回傳的目錄一定是這次建立的
+func newServerDir(serverType, serverVer, ownerID string) (string, string, error) {
+	if err := os.MkdirAll(common.MinecraftServerPath, 0755); err != nil {
+		return "", "", err
+	}
+	for i := 0; i < serverIDAttempts; i++ {
+		serverID, err := newServerID(serverType, serverVer, ownerID)
+		if err != nil {
+			return "", "", err
+		}
+		inUse, err := model.ServerIDInUse(serverID)
+		if err != nil {
+			return "", "", err
+		}
+		if inUse {
+			continue
+		}
+		dir := filepath.Join(common.MinecraftServerPath, serverID)
+		err = os.Mkdir(dir, 0755)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", "", err
+		}
+		return serverID, dir, nil
+	}
+	return "", "", fmt.Errorf("no free server id after %d attempts", serverIDAttempts)
+}
+
+// ServerTypeFromID 舊資料沒有存類型，從 server id 的前綴判斷
+func ServerTypeFromID(serverID string) string {
+	switch {
+	case strings.HasPrefix(serverID, "mcsfv-"):
+		return "Fabric"
+	case strings.HasPrefix(serverID, "mcsvv-"):
+		return "Vanilla"
+	}
+	return ""
+}
+
+// CloneServer 複製成一台新的伺服器，回傳新的 server ID；port 在啟動時才會分配
+func (s *ServerService) CloneServer(srcID, srcDir, ownerID, serverType, serverVer string, opts CloneOptions) (string, error) {
+	if opts.World && s.mgr.isAlive(srcID) {
+		return "", ErrCloneWhileRunning
+	}
+	serverID, dst, err := newServerDir(serverType, serverVer, ownerID)
+	if err != nil {
+		return "", err
+	}
+	if err := copyServerFiles(srcDir, dst, opts); err != nil {
+		_ = ErrorFileClear(dst)
+		return "", err
+	}
+	if opts.Mods {
+		if err := model.CopyServerMods(srcID, serverID); err != nil {
+			_ = ErrorFileClear(dst)
+			return "", err
+		}
+	}
+	return serverID, nil
+}
+
+// SaveTemplate 把伺服器存成模板目錄，回傳模板路徑
+func (s *ServerService) SaveTemplate(srcID, srcDir, templateKey string, opts CloneOptions) (string, error) {
+	if opts.World && s.mgr.isAlive(srcID) {
+		return "", ErrCloneWhileRunning
+	}
+	if strings.ContainsAny(templateKey, `/\`) || templateKey == "" || templateKey == "." || templateKey == ".." {
+		return "", fmt.Errorf("invalid template key %q", templateKey)
+	}
+	if err := os.MkdirAll(common.MinecraftTemplatePath, 0755); err != nil {
+		return "", err
+	}
+	// 已經存在的話是別的模板，不能覆蓋也不能清掉
+	dst := filepath.Join(common.MinecraftTemplatePath, templateKey)
+	if err := os.Mkdir(dst, 0755); err != nil {
+		return "", err
+	}
+	if err := copyServerFiles(srcDir, dst, opts); err != nil {
+		_ = ErrorFileClear(dst)
+		return "", err
+	}
+	return dst, nil
+}
+
+// CreateFromTemplate 模板裡有什麼就全部複製
+func CreateFromTemplate(templatePath, ownerID, serverType, serverVer string) (string, error) {
+	if _, err := SelectJavaRuntime(serverVer, 0); err != nil {
+		return "", err
+	}
+	serverID, dst, err := newServerDir(serverType, serverVer, ownerID)
+	if err != nil {
+		return "", err
+	}
+	if err := common.Copy(templatePath, dst); err != nil {
+		_ = ErrorFileClear(dst)
+		return "", err
+	}
+	return serverID, nil
+}
+
+func RemoveTemplateDir(templatePath string) error {
+	return os.RemoveAll(templatePath)
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyServerFilesWorldLevelName(t *testing.T) {
+	src := t.TempDir()
+	mustWrite(t, filepath.Join(src, "server.properties"), "level-name=survival\n")
+	mustWrite(t, filepath.Join(src, "survival", "level.dat"), "x")
+	mustWrite(t, filepath.Join(src, "survival", "session.lock"), "x")
+	mustWrite(t, filepath.Join(src, "survival_nether", "DIM-1", "a"), "x")
+
+	tests := []struct {
+		name       string
+		properties bool
+		world      string
+	}{
+		{"without properties uses default world", false, "world"},
+		{"with properties keeps level-name", true, "survival"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := filepath.Join(t.TempDir(), "clone")
+			if err := copyServerFiles(src, dst, CloneOptions{World: true, Properties: tt.properties}); err != nil {
+				t.Fatal(err)
+			}
+			if got := levelName(dst); got != tt.world {
+				t.Fatalf("level-name = %q, want %q", got, tt.world)
+			}
+			if _, err := os.Stat(filepath.Join(dst, tt.world, "level.dat")); err != nil {
+				t.Fatalf("world not copied to %s: %v", tt.world, err)
+			}
+			if _, err := os.Stat(filepath.Join(dst, tt.world+"_nether", "DIM-1", "a")); err != nil {
+				t.Fatalf("nether not copied: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(dst, tt.world, "session.lock")); !os.IsNotExist(err) {
+				t.Fatalf("session.lock should be removed")
+			}
+		})
+	}
+}
+
+func TestCopyServerFilesRefusesExistingServer(t *testing.T) {
+	src := t.TempDir()
+	mustWrite(t, filepath.Join(src, "server.jar"), "new")
+	dst := t.TempDir()
+	mustWrite(t, filepath.Join(dst, "server.jar"), "live")
+
+	if err := copyServerFiles(src, dst, CloneOptions{}); err == nil {
+		t.Fatal("expected an error for a non-empty destination")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "server.jar")); string(data) != "live" {
+		t.Fatalf("existing server was overwritten: %q", data)
+	}
+}
+
+func mustWrite(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,12 +3,13 @@
 package service
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"go-backend/common"
+	"go-backend/model"
 	"io"
+	"os"
 	"os/exec"
 	"sync"
 	"time"
@@ -22,57 +23,105 @@ const (
 var ErrAlreadyRunning = errors.New("server already running")
 var ErrNotFound = errors.New("Server Not Found.")
 var ErrMaxReached = errors.New("User has reached the maximum number of servers")
-var ErrServerRunning = errors.New("Cannot Backup while server is running")
+var ErrServerRunning = errors.New("Cannot do this while server is running")
+var ErrConsoleDetached = errors.New("console is not attached to this server")
+var ErrServerDeleting = errors.New("server is being deleted")
+
+// LaunchConfig 啟動 java 需要的設定
+type LaunchConfig struct {
+	Java     string // java 執行檔路徑
+	MaxMem   string
+	MinMem   string
+	JvmFlags []string
+	Args     []string // 接在 server.jar 後面的參數
+	Restart  RestartPolicy
+	Limits   ResourceLimits
+}
 
 type Server struct {
 	sid          string
 	oid          string
 	workDir      string
-	maxMem       string
-	minMem       string
+	launch       LaunchConfig
 	port         string
+	rconPort     string // 空的代表沒有分到 rcon port
+	rconPassword string
+	rcon         *rconClient
+	rconMu       sync.Mutex
 	cmd          *exec.Cmd
+	proc         *os.Process
+	pid          int
+	cgroup       string        // 空的代表沒有放進 cgroup
+	exited       chan struct{} // process 結束時關閉
 	stdin        io.Writer
-	stdout       io.Reader
-	logBuffer    *bytes.Buffer
-	serverStatus string
+	logBuffer    *ringBuffer
+	logFile      *consoleLogFile
+	logMu        sync.Mutex // 讓寫入 buffer/檔案 和推送給訂閱者保持同一個順序
+	console      *consoleHub
+	metrics      *metricsSeries
+	state        ServerState
+	stateSince   time.Time
+	startedAt    time.Time
+	bootDuration time.Duration
 	exp          time.Time
+	exit         exitInfo
+	restart      restartState
+	pingCache    pingCache
+	pingMu       sync.Mutex
 	sdc          func(string)
-	args         []string
 	mu           sync.RWMutex
 }
 
-func NewServer(sid, oid, workDir, maxMem, minMem string, portStr string, callback func(string), args []string) *Server {
+func NewServer(sid, oid, workDir string, launch LaunchConfig, portStr string, callback func(string)) *Server {
 	return &Server{
-		sid:          sid,
-		oid:          oid,
-		workDir:      workDir,
-		maxMem:       maxMem,
-		minMem:       minMem,
-		port:         portStr,
-		serverStatus: "stopped",
-		sdc:          callback,
-		args:         args,
-		logBuffer:    &bytes.Buffer{},
+		sid:        sid,
+		oid:        oid,
+		workDir:    workDir,
+		launch:     launch,
+		port:       portStr,
+		state:      StateCreated,
+		stateSince: time.Now(),
+		sdc:        callback,
+		logBuffer:  newRingBuffer(common.ConsoleBufferSize),
+		console:    newConsoleHub(),
+		metrics:    newMetricsSeries(common.MetricsHistorySize),
 	}
 }
 
+// Start 手動啟動，會清掉自動重啟的計數
 func (s *Server) Start() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if s.serverStatus == "running" {
+	if s.state.Alive() {
 		return ErrAlreadyRunning
 	}
+	s.resetRestartWithOutLock()
+	return s.startWithOutLock()
+}
+
+func (s *Server) startWithOutLock() error {
+	if !canTransition(s.state, StateStarting) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, s.state, StateStarting)
+	}
 	// 建立命令參數
 	cmdArgs := []string{
-		"-Xms" + s.minMem,
-		"-Xmx" + s.maxMem,
-		"-jar", "server.jar",
-		"--port", s.port,
+		"-Xms" + s.launch.MinMem,
+		"-Xmx" + s.launch.MaxMem,
+	}
+	cmdArgs = append(cmdArgs, s.launch.JvmFlags...)
+	cmdArgs = append(cmdArgs, "-jar", "server.jar", "--port", s.port)
+	cmdArgs = append(cmdArgs, s.launch.Args...)
+	if err := s.enableRconWithOutLock(); err != nil {
+		// 寫不進去就只能用 stdin
+		common.SysError(fmt.Sprintf("server %s enable rcon error: %s", s.sid, err.Error()))
+	}
+	java := s.launch.Java
+	if java == "" {
+		java = "java"
 	}
-	cmdArgs = append(cmdArgs, s.args...)
-	cmd := exec.CommandContext(context.Background(), "java", cmdArgs...)
+	cmd := exec.CommandContext(context.Background(), java, cmdArgs...)
 	cmd.Dir = s.workDir
+	setProcAttr(cmd)
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		return err
@@ -83,66 +132,163 @@ func (s *Server) Start() error {
 	}
 	cmd.Stderr = cmd.Stdout
 
+	cgroup, err := createCgroup(s.sid, s.launch.Limits)
+	if err != nil {
+		common.SysError(fmt.Sprintf("WARNING: server %s cgroup setup failed, running without limits: %s", s.sid, err.Error()))
+		cgroup = ""
+	}
+
 	s.cmd = cmd
 	s.stdin = stdin
-	s.stdout = stdout
-	s.logBuffer.Reset()
-
 	if err := cmd.Start(); err != nil {
+		removeCgroup(cgroup)
 		return err
 	}
-	s.serverStatus = "running"
+	if cgroup != "" {
+		if err := addToCgroup(cgroup, cmd.Process.Pid); err != nil {
+			common.SysError(fmt.Sprintf("WARNING: server %s cannot join cgroup: %s", s.sid, err.Error()))
+			removeCgroup(cgroup)
+			cgroup = ""
+		}
+	}
+	s.cgroup = cgroup
+	s.logMu.Lock()
+	s.logBuffer.Reset()
+	s.openLogFile()
+	s.logMu.Unlock()
+	s.proc = cmd.Process
+	s.pid = cmd.Process.Pid
+	s.exited = make(chan struct{})
+	_ = s.transitionWithOutLock(StateStarting)
+	s.startedAt = s.stateSince
+	s.bootDuration = 0
+	s.exit.stopRequested = false
 	s.exp = time.Now().Add(3 * time.Minute)
-	go s.captureLogs()
-	go s.waitAndCleanup()
+	s.saveState()
+	logDone := make(chan struct{})
+	go s.captureLogs(stdout, logDone)
+	go s.waitAndCleanup(cmd, logDone, s.exited)
 	return nil
 }
 
-func (s *Server) captureLogs() {
-	io.Copy(s.logBuffer, s.stdout)
+// waitAndCleanup 只有這裡會呼叫 cmd.Wait，其他地方一律等 exited
+// Wait 會關掉 stdout pipe，所以要先等 captureLogs 讀完
+func (s *Server) waitAndCleanup(cmd *exec.Cmd, logDone chan struct{}, exited chan struct{}) {
+	<-logDone
+	if err := cmd.Wait(); err != nil {
+		common.SysDebug(fmt.Sprintf("server %s exited: %s", s.sid, err.Error()))
+	}
+	s.markExited(exited, cmd.ProcessState.ExitCode())
 }
 
-func (s *Server) waitAndCleanup() {
-	s.cmd.Wait()
+// markExited 不是透過 Stop 結束且 exit code 不為 0 的視為 crash
+// 重新接管的 process 拿不到 exit code，不是我們停的就當成 crash，不然 on-failure 永遠不會重啟
+// 先更新狀態再關 exited，Stop 回傳時狀態就已經是 stopped
+func (s *Server) markExited(exited chan struct{}, exitCode int) {
+	s.logMu.Lock()
+	if s.logFile != nil {
+		s.logFile.Close()
+		s.logFile = nil
+	}
+	s.logMu.Unlock()
+
 	s.mu.Lock()
-	s.serverStatus = "stopped"
+	requested := s.exit.stopRequested
+	crashed := !requested && exitCode != 0
+	s.exit.code = exitCode
+	s.exit.at = time.Now()
+	s.exit.crashed = crashed
+	next := StateStopped
+	if crashed {
+		next = StateCrashed
+	}
+	if err := s.transitionWithOutLock(next); err != nil {
+		common.SysError(err.Error())
+	}
 	s.exp = time.Now().Add(3 * time.Minute)
+	cgroup := s.cgroup
+	s.cgroup = ""
 	s.mu.Unlock()
+	close(exited)
+
+	s.closeRcon()
+	removeCgroup(cgroup)
+	s.removeState()
+	s.recordExit(exitCode, crashed, requested)
+	s.handleExit(crashed, requested)
 }
 
+// Stop 等待期間不持有 s.mu，console 還能繼續寫入、狀態也查得到 stopping
 func (s *Server) Stop() error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	if s.cmd == nil || s.serverStatus != "running" {
+	if s.cancelRestartWithOutLock() {
+		// 還在等自動重啟，取消就算停止了
+		s.mu.Unlock()
+		return nil
+	}
+	if s.proc == nil || !s.state.Alive() {
+		s.mu.Unlock()
 		return errors.New("server not running")
 	}
+	exited, proc := s.exited, s.proc
+	if s.state == StateStopping {
+		// 已經有人在關了，一起等
+		s.mu.Unlock()
+		<-exited
+		return nil
+	}
+	if err := s.transitionWithOutLock(StateStopping); err != nil {
+		s.mu.Unlock()
+		return err
+	}
 
-	_, _ = io.WriteString(s.stdin, "stop\n")
+	s.exit.stopRequested = true
+	if s.stdin != nil {
+		_, _ = io.WriteString(s.stdin, "stop\n")
+	} else if err := terminateProcess(proc); err != nil {
+		// 重新接管的 process 沒有 stdin 只能送 signal
+		common.SysError(err.Error())
+	}
+	s.mu.Unlock()
 
 	timeout := 30 * time.Second
-	done := make(chan error, 1)
-	go func() {
-		done <- s.cmd.Wait()
-	}()
-
 	select {
 	case <-time.After(timeout):
 		// 超時，強制 kill
-		if s.cmd.Process != nil {
-			_ = s.cmd.Process.Kill()
-		}
-		<-done // 等候 goroutine 結束（可以忽略 error）
-	case err := <-done:
-		if err != nil {
-			common.SysError(err.Error())
-		}
+		_ = proc.Kill()
+		<-exited
+	case <-exited:
 	}
-
-	s.serverStatus = "stopped"
-	s.exp = time.Now().Add(3 * time.Minute)
 	return nil
 }
 
+// expired 停止超過保留時間、也沒有在等自動重啟，可以回收 port
+func (s *Server) expired(now time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.idleWithOutLock() && s.exp.Before(now)
+}
+
+// idleWithOutLock 沒在跑也沒有在等自動重啟，呼叫時必須持有 s.mu
+func (s *Server) idleWithOutLock() bool {
+	return !s.state.Alive() && s.restart.timer == nil
+}
+
+func (s *Server) idle() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.idleWithOutLock()
+}
+
+// setLaunchConfig 執行中不會改，下次啟動才生效
+func (s *Server) setLaunchConfig(launch LaunchConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.state.Alive() {
+		s.launch = launch
+	}
+}
+
 func (s *Server) Restart() error {
 	if err := s.Stop(); err != nil {
 		return err
@@ -151,9 +297,7 @@ func (s *Server) Restart() error {
 }
 
 func (s *Server) Status() string {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	return s.serverStatus
+	return string(s.State())
 }
 
 func (s *Server) Port() string {
@@ -169,22 +313,19 @@ func (s *Server) ID() string {
 }
 
 func (s *Server) ReadLatestLog() string {
-	data := s.logBuffer.String()
-	if len(data) > (1024 * 8) {
-		s.mu.Lock()
-		defer s.mu.Unlock()
-		return data[len(data)-(1024*8):]
-	}
-	return data
+	return s.logBuffer.Tail(1024 * 8)
 }
 
-// SendCommand 發送指令到伺服器 stdin
+// SendCommand 發送指令到伺服器 stdin，要拿到輸出用 RunCommand
 func (s *Server) SendCommand(cmd string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if s.serverStatus != "running" {
+	if !s.state.Alive() {
 		return errors.New("server not running")
 	}
+	if s.stdin == nil {
+		return ErrConsoleDetached
+	}
 	_, err := io.WriteString(s.stdin, cmd+"\n")
 	return err
 }
@@ -193,7 +334,7 @@ func (s *Server) SetProperty(key, value string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.serverStatus == "running" {
+	if s.state.Alive() {
 		return errors.New("必須先停止 server 才能修改 server.properties")
 	}
 	return UpdateProperty(s.workDir, key, value)
@@ -201,11 +342,11 @@ func (s *Server) SetProperty(key, value string) error {
 
 func (s *Server) ShutDown() error {
 	s.mu.RLock()
-	status := s.serverStatus
+	alive := s.state.Alive()
 	callback := s.sdc
 	sid := s.sid
 	s.mu.RUnlock()
-	if status == "running" {
+	if alive {
 		if err := s.Stop(); err != nil {
 			return err
 		}
@@ -218,18 +359,31 @@ func (s *Server) ShutDown() error {
 
 type ServerManager struct {
 	servers        map[string]*Server
+	pool           map[int]bool // 設定檔裡的所有 port
 	availablePorts []int
-	usingPorts     map[int]string //port -> server ID
+	usingPorts     map[int]string  //port -> server ID
+	reserved       map[int]string  // port -> note
+	deleting       map[string]bool // 刪除中或已在垃圾桶的 server ID，不能再啟動
 	mu             sync.RWMutex
 }
 
 func NewServerManager(ports []int) *ServerManager {
 	sm := &ServerManager{
 		servers:        make(map[string]*Server),
+		pool:           make(map[int]bool),
 		availablePorts: ports,
 		usingPorts:     make(map[int]string),
+		reserved:       make(map[int]string),
+		deleting:       make(map[string]bool),
+	}
+	for _, p := range ports {
+		sm.pool[p] = true
 	}
+	allocs := sm.loadReservedPorts()
+	sm.recover()
+	sm.dropStaleAllocations(allocs)
 	go sm.cleanupExpired()
+	go sm.sampleMetricsLoop()
 	return sm
 }
 
@@ -245,56 +399,77 @@ func (sm *ServerManager) countByOwner(oid string) int {
 	return count
 }
 
-func (sm *ServerManager) allocatePort() (int, error) {
+// allocatePort 從 pool 拿一個 port 綁定給 sid
+// 被其他程式佔用的 port (例如上次沒關乾淨的 java) 先跳過，留在 pool 裡下次再試
+func (sm *ServerManager) allocatePort(sid, kind string) (int, error) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
-	if len(sm.availablePorts) == 0 {
-		return 0, errors.New("no available ports")
+	for i, port := range sm.availablePorts {
+		if !common.CheckPortAvailable(port) {
+			common.SysError(fmt.Sprintf("port %d is in use by another process, skip", port))
+			continue
+		}
+		sm.availablePorts = append(sm.availablePorts[:i:i], sm.availablePorts[i+1:]...)
+		sm.usingPorts[port] = sid
+		sm.persistPortWithOutLock(port, sid, kind)
+		return port, nil
 	}
-	port := sm.availablePorts[0]
-	sm.availablePorts = sm.availablePorts[1:]
-	sm.usingPorts[port] = ""
-	return port, nil
-}
-
-// assignPortToServer 將 port 綁定給 sid
-func (sm *ServerManager) assignPortToServer(port int, sid string) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
-	sm.usingPorts[port] = sid
+	return 0, errors.New("no available ports")
 }
 
 // releasePort 釋放回 pool
 func (sm *ServerManager) releasePort(portStr string) {
-	var port int
-	fmt.Sscanf(portStr, "%d", &port)
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
-	delete(sm.usingPorts, port)
-	sm.availablePorts = append(sm.availablePorts, port)
+	sm.releasePortWithOutLock(portStr)
 }
 
 func (sm *ServerManager) releasePortWithOutLock(portStr string) {
+	if portStr == "" {
+		return
+	}
 	var port int
 	fmt.Sscanf(portStr, "%d", &port)
+	if _, used := sm.usingPorts[port]; !used {
+		return
+	}
 	delete(sm.usingPorts, port)
-	sm.availablePorts = append(sm.availablePorts, port)
+	if err := model.RemovePortAllocation(port); err != nil {
+		common.SysError("remove port allocation error: " + err.Error())
+	}
+	// 設定改過之後不在範圍內的 port 就不放回去
+	if sm.pool[port] {
+		sm.availablePorts = append(sm.availablePorts, port)
+	}
 }
 
-func (sm *ServerManager) StartServer(sid, oid, workDir, maxMem, minMem string, args []string) (*Server, error) {
+// releaseServerPortsWithOutLock 遊戲 port 和 rcon port 一起釋放，呼叫時必須持有 sm.mu
+func (sm *ServerManager) releaseServerPortsWithOutLock(srv *Server) {
+	sm.releasePortWithOutLock(srv.port)
+	sm.releasePortWithOutLock(srv.rconPort)
+}
+
+func (sm *ServerManager) StartServer(sid, oid, workDir string, launch LaunchConfig) (*Server, error) {
 	if sm.countByOwner(oid) >= MaxServersPerOwner {
 		return nil, ErrMaxReached
 	}
 
 	sm.mu.Lock()
+	if sm.deleting[sid] {
+		sm.mu.Unlock()
+		return nil, ErrServerDeleting
+	}
 	if s, exists := sm.servers[sid]; exists {
+		s.setLaunchConfig(launch)
 		err := s.Start()
 		if err != nil && errors.Is(err, ErrAlreadyRunning) {
 			sm.mu.Unlock()
 			common.SysDebug("server already running sid: " + sid)
 			return s, nil
 		} else if err != nil {
-			panic("Unknow error:" + err.Error())
+			// 例如 java 被移除了，不能在持有 sm.mu 的時候 panic
+			sm.mu.Unlock()
+			return nil, err
 		}
 		sm.mu.Unlock()
 		common.SysDebug("Server is running: " + sid)
@@ -302,25 +477,35 @@ func (sm *ServerManager) StartServer(sid, oid, workDir, maxMem, minMem string, a
 	}
 	sm.mu.Unlock()
 
-	var allocatedPort int
-	p, err := sm.allocatePort()
+	p, err := sm.allocatePort(sid, model.PortKindGame)
 	if err != nil {
 		return nil, err
 	}
-	allocatedPort = p
 	portStr := fmt.Sprintf("%d", p)
 
-	srv := NewServer(sid, oid, workDir, maxMem, minMem, portStr, sm.shutDownServerCallback, args)
-	sm.assignPortToServer(allocatedPort, sid)
+	srv := NewServer(sid, oid, workDir, launch, portStr, sm.shutDownServerCallback)
+
+	// rcon 另外分一個 port，pool 不夠就只用 stdin
+	if rp, err := sm.allocatePort(sid, model.PortKindRcon); err == nil {
+		srv.setRcon(fmt.Sprintf("%d", rp), "")
+	} else {
+		common.SysError(fmt.Sprintf("server %s no port for rcon: %s", sid, err.Error()))
+	}
 
 	sm.mu.Lock()
+	// 分配 port 的時候沒拿著鎖，這段時間可能開始刪除了
+	if sm.deleting[sid] {
+		sm.releaseServerPortsWithOutLock(srv)
+		sm.mu.Unlock()
+		return nil, ErrServerDeleting
+	}
 	sm.servers[sid] = srv
 	sm.mu.Unlock()
 
 	if err := srv.Start(); err != nil {
 		sm.mu.Lock()
 		delete(sm.servers, sid)
-		sm.releasePort(portStr)
+		sm.releaseServerPortsWithOutLock(srv)
 		sm.mu.Unlock()
 		return nil, err
 	}
@@ -328,20 +513,16 @@ func (sm *ServerManager) StartServer(sid, oid, workDir, maxMem, minMem string, a
 	return srv, nil
 }
 
-func (sm *ServerManager) SendCommand(sid string, cmd string) error {
+func (sm *ServerManager) SendCommand(sid string, cmd string) (CommandResult, error) {
 	sm.mu.RLock()
 	srv, exists := sm.servers[sid]
 	sm.mu.RUnlock()
 
 	if !exists {
-		return ErrNotFound
+		return CommandResult{}, ErrNotFound
 	}
 
-	if err := srv.SendCommand(cmd); err != nil {
-		return err
-	}
-
-	return nil
+	return srv.RunCommand(cmd)
 }
 
 func (sm *ServerManager) StopServer(sid string) error {
@@ -383,12 +564,22 @@ func (sm *ServerManager) GetServerStatus(sid string) (string, error) {
 	return srv.Status(), nil
 }
 
+func (sm *ServerManager) GetServerStatusInfo(sid string) ServerStatusInfo {
+	sm.mu.RLock()
+	srv, exists := sm.servers[sid]
+	sm.mu.RUnlock()
+	if !exists {
+		return ServerStatusInfo{State: StateStopped}
+	}
+	return srv.StatusInfo()
+}
+
 func (sm *ServerManager) shutDownServerCallback(sid string) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 	// shut down server時必須釋放port
 	srv := sm.servers[sid]
-	sm.releasePortWithOutLock(srv.port)
+	sm.releaseServerPortsWithOutLock(srv)
 	delete(sm.servers, sid)
 }
 
@@ -404,20 +595,17 @@ func (sm *ServerManager) ReadLatestLog(sid string) (string, error) {
 	return srv.ReadLatestLog(), nil
 }
 
-func (sm *ServerManager) BackUp(sid, workDir string) error {
+func (sm *ServerManager) SubscribeConsole(sid string) (string, <-chan string, func(), error) {
 	sm.mu.RLock()
 	srv, exists := sm.servers[sid]
 	sm.mu.RUnlock()
 
-	if exists && srv.Status() == "running" {
-		return ErrServerRunning
+	if !exists {
+		return "", nil, nil, ErrNotFound
 	}
 
-	src := workDir + "/world"
-	dst := workDir + "/backup/" + time.Now().Format("20060102_150405")
-
-	return common.Copy(src, dst)
-
+	backlog, ch, cancel := srv.SubscribeConsole()
+	return backlog, ch, cancel, nil
 }
 
 func (sm *ServerManager) cleanupExpired() {
@@ -427,10 +615,8 @@ func (sm *ServerManager) cleanupExpired() {
 		now := time.Now()
 		sm.mu.Lock()
 		for sid, srv := range sm.servers {
-			s := srv.Status()
-			isExp := srv.exp.Before(now)
-			if s == "stopped" && isExp {
-				sm.releasePortWithOutLock(srv.port)
+			if srv.expired(now) {
+				sm.releaseServerPortsWithOutLock(srv)
 				delete(sm.servers, sid)
 				common.SysLog(fmt.Sprintf("Server: %s del, port: %s", sid, srv.port))
 			}
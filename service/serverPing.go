@@ -0,0 +1,263 @@
+// service/serverPing.go
+// Server List Ping (handshake + status)，確認伺服器真的能接受連線
+
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	pingTimeout      = 3 * time.Second
+	pingMaxResponse  = 1 << 20 // status JSON 含 favicon，1MB 很夠了
+	pingProtocolAuto = -1      // 不知道版本時用 -1，伺服器會回自己的 protocol
+)
+
+var ErrServerNotRunning = errors.New("server not running")
+
+type PingPlayer struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+// PingResult status 回應整理過後的內容
+type PingResult struct {
+	Version  string       `json:"version"`
+	Protocol int          `json:"protocol"`
+	Motd     string       `json:"motd"`
+	Online   int          `json:"online"`
+	Max      int          `json:"max"`
+	Sample   []PingPlayer `json:"sample"`
+	Latency  int64        `json:"latency"` // ms
+	PingedAt time.Time    `json:"pinged_at"`
+}
+
+type pingCache struct {
+	result *PingResult
+	err    error
+	at     time.Time
+}
+
+type statusResponse struct {
+	Version struct {
+		Name     string `json:"name"`
+		Protocol int    `json:"protocol"`
+	} `json:"version"`
+	Players struct {
+		Max    int          `json:"max"`
+		Online int          `json:"online"`
+		Sample []PingPlayer `json:"sample"`
+	} `json:"players"`
+	Description json.RawMessage `json:"description"`
+}
+
+// PingServer 對 addr 送 handshake + status request，再用 ping/pong 量延遲
+func PingServer(addr string, timeout time.Duration) (*PingResult, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	r := bufio.NewReader(conn)
+
+	// handshake: protocol, host, port, next state = 1 (status)
+	var hs bytes.Buffer
+	writeVarInt(&hs, pingProtocolAuto)
+	writeVarInt(&hs, int32(len(host)))
+	hs.WriteString(host)
+	_ = binary.Write(&hs, binary.BigEndian, uint16(port))
+	writeVarInt(&hs, 1)
+	if err := writeMCPacket(conn, 0x00, hs.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := writeMCPacket(conn, 0x00, nil); err != nil {
+		return nil, err
+	}
+
+	id, data, err := readMCPacket(r)
+	if err != nil {
+		return nil, err
+	}
+	if id != 0x00 {
+		return nil, fmt.Errorf("unexpected status packet id %d", id)
+	}
+	body := bytes.NewReader(data)
+	n, err := readVarInt(body)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || int(n) > body.Len() {
+		return nil, fmt.Errorf("bad status string length %d", n)
+	}
+	raw := make([]byte, n)
+	_, _ = io.ReadFull(body, raw)
+
+	var status statusResponse
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return nil, err
+	}
+
+	// ping 帶時間戳，伺服器原封不動回 pong
+	sent := time.Now()
+	var payload bytes.Buffer
+	_ = binary.Write(&payload, binary.BigEndian, sent.UnixMilli())
+	if err := writeMCPacket(conn, 0x01, payload.Bytes()); err != nil {
+		return nil, err
+	}
+	if id, _, err = readMCPacket(r); err != nil {
+		return nil, err
+	}
+	if id != 0x01 {
+		return nil, fmt.Errorf("unexpected pong packet id %d", id)
+	}
+
+	return &PingResult{
+		Version:  status.Version.Name,
+		Protocol: status.Version.Protocol,
+		Motd:     flattenChat(status.Description),
+		Online:   status.Players.Online,
+		Max:      status.Players.Max,
+		Sample:   status.Players.Sample,
+		Latency:  time.Since(sent).Milliseconds(),
+		PingedAt: time.Now(),
+	}, nil
+}
+
+// flattenChat description 可能是字串，也可能是 {"text": "...", "extra": [...]} 的 chat component
+func flattenChat(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var comp struct {
+		Text  string            `json:"text"`
+		Extra []json.RawMessage `json:"extra"`
+	}
+	if err := json.Unmarshal(raw, &comp); err != nil {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(comp.Text)
+	for _, e := range comp.Extra {
+		sb.WriteString(flattenChat(e))
+	}
+	return sb.String()
+}
+
+// 封包格式：VarInt length | VarInt packet id | data
+func writeMCPacket(w io.Writer, id int32, data []byte) error {
+	var body bytes.Buffer
+	writeVarInt(&body, id)
+	body.Write(data)
+	var pkt bytes.Buffer
+	writeVarInt(&pkt, int32(body.Len()))
+	pkt.Write(body.Bytes())
+	_, err := w.Write(pkt.Bytes())
+	return err
+}
+
+func readMCPacket(r io.ByteReader) (int32, []byte, error) {
+	length, err := readVarInt(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if length <= 0 || length > pingMaxResponse {
+		return 0, nil, fmt.Errorf("bad packet length %d", length)
+	}
+	data := make([]byte, length)
+	for i := range data {
+		if data[i], err = r.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+	}
+	body := bytes.NewReader(data)
+	id, err := readVarInt(body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return id, data[len(data)-body.Len():], nil
+}
+
+func writeVarInt(buf *bytes.Buffer, v int32) {
+	u := uint32(v)
+	for {
+		if u&^0x7F == 0 {
+			buf.WriteByte(byte(u))
+			return
+		}
+		buf.WriteByte(byte(u&0x7F | 0x80))
+		u >>= 7
+	}
+}
+
+func readVarInt(r io.ByteReader) (int32, error) {
+	var result uint32
+	for i := 0; i < 5; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		result |= uint32(b&0x7F) << (7 * i)
+		if b&0x80 == 0 {
+			return int32(result), nil
+		}
+	}
+	return 0, errors.New("varint too long")
+}
+
+// Ping 結果快取 StatusPingCacheTTL 秒，dashboard 一直輪詢也不會一直打到伺服器
+// pingMu 同時讓同一台伺服器一次只有一個 probe
+func (s *Server) Ping() (*PingResult, error) {
+	s.pingMu.Lock()
+	defer s.pingMu.Unlock()
+
+	ttl := time.Duration(common.StatusPingCacheTTL) * time.Second
+	if !s.pingCache.at.IsZero() && time.Since(s.pingCache.at) < ttl {
+		return s.pingCache.result, s.pingCache.err
+	}
+
+	s.mu.RLock()
+	state, port := s.state, s.port
+	s.mu.RUnlock()
+	if state != StateRunning {
+		return nil, ErrServerNotRunning
+	}
+
+	result, err := PingServer(net.JoinHostPort("127.0.0.1", port), pingTimeout)
+	s.pingCache = pingCache{result: result, err: err, at: time.Now()}
+	return result, err
+}
+
+func (sm *ServerManager) PingServer(sid string) (*PingResult, error) {
+	sm.mu.RLock()
+	srv, exists := sm.servers[sid]
+	sm.mu.RUnlock()
+	if !exists {
+		return nil, ErrServerNotRunning
+	}
+	return srv.Ping()
+}
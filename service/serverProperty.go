@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
 	"time"
 )
@@ -50,7 +51,7 @@ func UpdateProperty(workDir, key, value string) error {
 	path := workDir + "/server.properties"
 	_ = backUp(path, path+".bak")
 
-	f, err := read(path)
+	f, err := read(workDir)
 
 	if err != nil {
 		return err
@@ -108,6 +109,90 @@ func UpdateProperty(workDir, key, value string) error {
 
 }
 
+// UpdateProperties 一次改多個 key，沒有的 key 加在最後面
+func UpdateProperties(workDir string, kv map[string]string) error {
+	path := workDir + "/server.properties"
+	_ = backUp(path, path+".bak")
+
+	f, err := read(workDir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	var lines []string
+	found := map[string]bool{}
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmedLine := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmedLine, "#") || trimmedLine == "" {
+			lines = append(lines, line)
+			continue
+		}
+		parts := strings.SplitN(trimmedLine, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if value, ok := kv[key]; ok && len(parts) == 2 {
+			lines = append(lines, fmt.Sprintf("%s=%s", key, value))
+			found[key] = true
+		} else {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+
+	keys := make([]string, 0, len(kv))
+	for key := range kv {
+		if !found[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf("%s=%s", key, kv[key]))
+	}
+
+	tmpPath := path + ".tmp"
+	joined := strings.Join(lines, "\n") + "\n"
+	if err := os.WriteFile(tmpPath, []byte(joined), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// readProperties 讀成 key -> value，註解和空行略過
+func readProperties(workDir string) (map[string]string, error) {
+	data, err := os.ReadFile(workDir + "/server.properties")
+	if err != nil {
+		return nil, err
+	}
+	props := map[string]string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		props[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return props, nil
+}
+
+// levelName 伺服器的世界資料夾名稱，沒設定就是 world
+func levelName(workDir string) string {
+	props, err := readProperties(workDir)
+	// 檔案管理或舊版寫進去的值沒檢查過，不合法的一律當成 world
+	if err != nil || checkLevelName(props["level-name"]) != nil {
+		return "world"
+	}
+	return props["level-name"]
+}
+
 func GetPropertyText(workDir string) (string, error) {
 	f, err := read(workDir)
 
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLevelName(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"survival", "survival"},
+		{"", "world"},
+		{".", "world"},
+		{"..", "world"},
+		{"../outside", "world"},
+		{"/tmp/world", "world"},
+		{"a/b", "world"},
+		{"C:world", "world"},
+		{"backups", "world"},
+		{"mods", "world"},
+		{"logs", "world"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			dir := t.TempDir()
+			mustWrite(t, filepath.Join(dir, "server.properties"), "level-name="+tt.value+"\n")
+			if got := levelName(dir); got != tt.want {
+				t.Fatalf("levelName = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if got := levelName(t.TempDir()); got != "world" {
+		t.Fatalf("missing server.properties: got %q", got)
+	}
+}
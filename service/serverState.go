@@ -0,0 +1,196 @@
+// service/serverState.go
+// 把 ServerManager 的狀態存進 DB，後端重啟後重新接管或重開伺服器
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-backend/common"
+	"go-backend/model"
+	"os"
+	"strconv"
+	"time"
+)
+
+// saveState 呼叫時必須持有 s.mu
+func (s *Server) saveState() {
+	args, _ := json.Marshal(s.launch.Args)
+	jvmFlags, _ := json.Marshal(s.launch.JvmFlags)
+	limits, _ := json.Marshal(s.launch.Limits)
+	port, _ := strconv.Atoi(s.port)
+	rconPort, _ := strconv.Atoi(s.rconPort)
+	state := &model.MinecraftServerState{
+		ServerID: s.sid,
+		OwnerID:  s.oid,
+		Pid:      s.pid,
+		Port:     port,
+		RconPort: rconPort,
+		RconPass: s.rconPassword,
+		WorkDir:  s.workDir,
+		JavaPath: s.launch.Java,
+		MaxMem:   s.launch.MaxMem,
+		MinMem:   s.launch.MinMem,
+		JvmFlags: string(jvmFlags),
+		Args:     string(args),
+		Restart:  s.launch.Restart.Mode,
+		Retries:  s.launch.Restart.MaxRetries,
+		Limits:   string(limits),
+	}
+	if err := model.SaveServerState(state); err != nil {
+		common.SysError("save server state error: " + err.Error())
+	}
+}
+
+func (s *Server) removeState() {
+	if err := model.RemoveServerState(s.sid); err != nil {
+		common.SysError("remove server state error: " + err.Error())
+	}
+}
+
+// reattach 接管上一次後端留下來、還活著的 java process
+// 拿不到 stdin/stdout，所以只能用 signal 停止，log 改追 logs/latest.log
+func (s *Server) reattach(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.proc = p
+	s.pid = pid
+	s.exited = make(chan struct{})
+	if err := s.transitionWithOutLock(StateRunning); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.exp = time.Now().Add(3 * time.Minute)
+	// 上次放進的 cgroup 還在的話繼續用它讀用量
+	if path := serverCgroupPath(s.sid); path != "" {
+		if _, err := os.Stat(path); err == nil {
+			s.cgroup = path
+		}
+	}
+	exited := s.exited
+	s.mu.Unlock()
+	s.logMu.Lock()
+	s.openLogFile()
+	s.logMu.Unlock()
+	go s.watchProcess(pid, exited)
+	go s.followLogFile(exited)
+	return nil
+}
+
+// watchProcess 不是自己的 child 沒辦法 Wait，只能定時檢查 pid
+func (s *Server) watchProcess(pid int, exited chan struct{}) {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !processAlive(pid) {
+			break
+		}
+	}
+	// 不是自己的 child 拿不到 exit code
+	s.markExited(exited, exitCodeUnknown)
+}
+
+// takePort 把 port 從可用清單拿掉並標記給 sid，呼叫時必須持有 sm.mu
+func (sm *ServerManager) takePort(port int, sid, kind string) {
+	for i, p := range sm.availablePorts {
+		if p == port {
+			sm.availablePorts = append(sm.availablePorts[:i], sm.availablePorts[i+1:]...)
+			break
+		}
+	}
+	sm.usingPorts[port] = sid
+	sm.persistPortWithOutLock(port, sid, kind)
+}
+
+// recover 讀取 DB 裡標記為執行中的伺服器：
+// process 還活著就重新接管，已經死掉就用原本的 port 重新啟動
+func (sm *ServerManager) recover() {
+	states, err := model.GetAllServerStates()
+	if err != nil {
+		common.SysError("load server states error: " + err.Error())
+		return
+	}
+
+	for _, st := range states {
+		var args, jvmFlags []string
+		var limits ResourceLimits
+		if st.Args != "" {
+			if err := json.Unmarshal([]byte(st.Args), &args); err != nil {
+				common.SysError(fmt.Sprintf("server %s has invalid args: %s", st.ServerID, err.Error()))
+			}
+		}
+		if st.JvmFlags != "" {
+			if err := json.Unmarshal([]byte(st.JvmFlags), &jvmFlags); err != nil {
+				common.SysError(fmt.Sprintf("server %s has invalid jvm flags: %s", st.ServerID, err.Error()))
+			}
+		}
+		// 舊版存下來、現在不允許的參數不帶進去
+		if err := ValidateJvmFlags(jvmFlags); err != nil {
+			common.SysError(fmt.Sprintf("server %s drop jvm flags: %s", st.ServerID, err.Error()))
+			jvmFlags = nil
+		}
+		if st.Limits != "" {
+			if err := json.Unmarshal([]byte(st.Limits), &limits); err != nil {
+				common.SysError(fmt.Sprintf("server %s has invalid limits: %s", st.ServerID, err.Error()))
+			}
+		}
+		// 舊版存下來的限制可能超過擁有者角色的上限，查不到角色就當一般使用者
+		role := common.RoleCommonUser
+		if uid, err := strconv.ParseUint(st.OwnerID, 10, 64); err == nil {
+			if r, err := model.GetRole(uint(uid)); err == nil {
+				role = r
+			}
+		}
+		limits = ClampResourceLimits(role, limits)
+		portStr := strconv.Itoa(st.Port)
+
+		sm.mu.Lock()
+		if owner, used := sm.usingPorts[st.Port]; used {
+			sm.mu.Unlock()
+			common.SysError(fmt.Sprintf("server %s port %d already used by %s, skip", st.ServerID, st.Port, owner))
+			_ = model.RemoveServerState(st.ServerID)
+			continue
+		}
+		launch := LaunchConfig{
+			Java:     st.JavaPath,
+			MaxMem:   st.MaxMem,
+			MinMem:   st.MinMem,
+			JvmFlags: jvmFlags,
+			Args:     args,
+			Restart:  RestartPolicy{Mode: st.Restart, MaxRetries: st.Retries},
+			Limits:   limits,
+		}
+		srv := NewServer(st.ServerID, st.OwnerID, st.WorkDir, launch, portStr, sm.shutDownServerCallback)
+		sm.takePort(st.Port, st.ServerID, model.PortKindGame)
+		if st.RconPort > 0 {
+			if _, used := sm.usingPorts[st.RconPort]; !used {
+				sm.takePort(st.RconPort, st.ServerID, model.PortKindRcon)
+				srv.rconPort = strconv.Itoa(st.RconPort)
+				srv.rconPassword = st.RconPass
+			}
+		}
+		sm.servers[st.ServerID] = srv
+		sm.mu.Unlock()
+
+		if processAlive(st.Pid) && isServerProcess(st.Pid, st.WorkDir) {
+			if err := srv.reattach(st.Pid); err == nil {
+				common.SysLog(fmt.Sprintf("Server: %s reattached, pid: %d, port: %d", st.ServerID, st.Pid, st.Port))
+				continue
+			}
+		}
+
+		if err := srv.Start(); err != nil {
+			common.SysError(fmt.Sprintf("Server: %s restart failed: %s", st.ServerID, err.Error()))
+			_ = model.RemoveServerState(st.ServerID)
+			sm.mu.Lock()
+			sm.releaseServerPortsWithOutLock(srv)
+			delete(sm.servers, st.ServerID)
+			sm.mu.Unlock()
+			continue
+		}
+		common.SysLog(fmt.Sprintf("Server: %s restarted, port: %d", st.ServerID, st.Port))
+	}
+}
@@ -0,0 +1,205 @@
+// service/serverTrash.go
+// 刪除伺服器：先停掉、釋放 port，目錄搬進垃圾桶，保留期內可以還原，過期由背景清掉
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"go-backend/model"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var ErrRestoreConflict = errors.New("server directory already exists")
+
+const trashPurgeInterval = 10 * time.Minute
+
+// RemoveServer 停止伺服器 (如果在跑) 並從 manager 拿掉，port 一起釋放
+func (sm *ServerManager) RemoveServer(sid string) error {
+	sm.mu.RLock()
+	srv, exists := sm.servers[sid]
+	sm.mu.RUnlock()
+	if !exists {
+		return nil
+	}
+
+	// Stop 也會取消等待中的自動重啟
+	if !srv.idle() {
+		if err := srv.Stop(); err != nil && srv.IsAlive() {
+			return err
+		}
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if cur, ok := sm.servers[sid]; ok && cur == srv {
+		sm.releaseServerPortsWithOutLock(srv)
+		delete(sm.servers, sid)
+	}
+	return nil
+}
+
+// markDeleting 標記之後 StartServer 會拒絕，刪除成功就一直留著直到還原
+func (sm *ServerManager) markDeleting(sid string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.deleting[sid] {
+		return ErrServerDeleting
+	}
+	sm.deleting[sid] = true
+	return nil
+}
+
+func (sm *ServerManager) unmarkDeleting(sid string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	delete(sm.deleting, sid)
+}
+
+func (sm *ServerManager) isAlive(sid string) bool {
+	sm.mu.RLock()
+	srv, exists := sm.servers[sid]
+	sm.mu.RUnlock()
+	return exists && srv.IsAlive()
+}
+
+// moveDir 不同磁碟之間 rename 會失敗，改成複製後刪除
+func moveDir(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := common.Copy(src, dst); err != nil {
+		_ = os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// TrashServerDir 把伺服器目錄搬進垃圾桶，回傳新的路徑
+func TrashServerDir(sid, workDir string) (string, error) {
+	dst := filepath.Join(common.MinecraftTrashPath, fmt.Sprintf("%s-%d", sid, time.Now().Unix()))
+	if _, err := os.Stat(workDir); os.IsNotExist(err) {
+		// 目錄早就不在了，只刪 DB
+		return "", nil
+	}
+	if err := moveDir(workDir, dst); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// RestoreServerDir 原本的位置已經有東西就不覆蓋
+func RestoreServerDir(trashPath, workDir string) error {
+	if _, err := os.Stat(workDir); err == nil {
+		return ErrRestoreConflict
+	}
+	if trashPath == "" {
+		return os.MkdirAll(workDir, os.ModePerm)
+	}
+	return moveDir(trashPath, workDir)
+}
+
+// DeleteServer 停止伺服器、目錄搬進垃圾桶、DB 標記刪除，回傳垃圾桶路徑
+// 整段拿著備份鎖，也不讓伺服器在中途被啟動
+func (s *ServerService) DeleteServer(ownerID uint, sid, workDir string) (string, error) {
+	unlock, err := lockBackup(sid)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	if err := s.mgr.markDeleting(sid); err != nil {
+		return "", err
+	}
+
+	if err := s.mgr.RemoveServer(sid); err != nil {
+		s.mgr.unmarkDeleting(sid)
+		return "", err
+	}
+	trashPath, err := TrashServerDir(sid, workDir)
+	if err != nil {
+		s.mgr.unmarkDeleting(sid)
+		return "", err
+	}
+	if err := model.TrashServer(ownerID, sid, trashPath); err != nil {
+		// DB 沒改成功就把目錄搬回去，不然伺服器還在但檔案不見了
+		if restoreErr := RestoreServerDir(trashPath, workDir); restoreErr != nil {
+			common.SysError(fmt.Sprintf("server %s restore dir error: %s", sid, restoreErr.Error()))
+		}
+		s.mgr.unmarkDeleting(sid)
+		return "", err
+	}
+	return trashPath, nil
+}
+
+// RestoreServer 目錄搬回原位再把 DB 改回來，DB 失敗就把目錄搬回垃圾桶
+func (s *ServerService) RestoreServer(ownerID uint, sid, trashPath, workDir string) error {
+	if err := RestoreServerDir(trashPath, workDir); err != nil {
+		return err
+	}
+	if err := model.RestoreTrashedServer(ownerID, sid); err != nil {
+		// DB 還是在垃圾桶裡，目錄要搬回 TrashPath，不然清垃圾桶時會留下沒人管的目錄
+		if undoErr := UndoRestoreServerDir(trashPath, workDir); undoErr != nil {
+			common.SysError(fmt.Sprintf("server %s undo restore error: %s", sid, undoErr.Error()))
+		}
+		return err
+	}
+	s.mgr.unmarkDeleting(sid)
+	return nil
+}
+
+// UndoRestoreServerDir RestoreServerDir 之後 DB 沒改成功時用，把目錄搬回垃圾桶
+func UndoRestoreServerDir(trashPath, workDir string) error {
+	if trashPath == "" {
+		// 只是建了一個空目錄
+		return os.Remove(workDir)
+	}
+	return moveDir(workDir, trashPath)
+}
+
+// TrashExpiresAt 垃圾桶中的伺服器什麼時候會被清掉
+func TrashExpiresAt(deletedAt time.Time) time.Time {
+	return deletedAt.Add(time.Duration(common.TrashGracePeriod) * time.Hour)
+}
+
+// StartTrashPurger 定期清掉超過保留期的伺服器
+func StartTrashPurger() {
+	go func() {
+		purgeTrash()
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			purgeTrash()
+		}
+	}()
+}
+
+func purgeTrash() {
+	before := time.Now().Add(-time.Duration(common.TrashGracePeriod) * time.Hour)
+	servers, err := model.GetExpiredTrashedServers(before)
+	if err != nil {
+		common.SysError("load trashed servers error: " + err.Error())
+		return
+	}
+	for _, srv := range servers {
+		if srv.TrashPath != "" {
+			if err := os.RemoveAll(srv.TrashPath); err != nil {
+				common.SysError(fmt.Sprintf("purge server %s error: %s", srv.ServerID, err.Error()))
+				continue
+			}
+		}
+		if err := model.PurgeServer(srv.OnwerID, srv.ServerID); err != nil {
+			common.SysError(fmt.Sprintf("purge server %s error: %s", srv.ServerID, err.Error()))
+			continue
+		}
+		_ = model.RemoveServerExits(srv.ServerID)
+		_ = model.RemovePropertyRevisions(srv.ServerID)
+		_ = model.RemoveServerMods(srv.ServerID)
+		common.SysLog("Server purged: " + srv.ServerID)
+	}
+}
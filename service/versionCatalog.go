@@ -0,0 +1,390 @@
+// service/versionCatalog.go
+// Vanilla / Fabric 版本清單：定期從設定的來源抓下來存進 DB，讀取時直接用 DB 的資料，過期了才在背景重新抓
+// 來源連不上時繼續用舊資料；Vanilla 連舊資料都沒有就退回 common/minecraft-server-jar-downloads.json
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"go-backend/model"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	CatalogVanilla         = "vanilla"
+	CatalogFabricGame      = "fabric-game"
+	CatalogFabricLoader    = "fabric-loader"
+	CatalogFabricInstaller = "fabric-installer"
+)
+
+// 抓失敗之後隔多久才會再試
+const catalogRetryInterval = 5 * time.Minute
+
+var (
+	ErrUnknownCatalog  = errors.New("unknown version catalog")
+	ErrVersionNotFound = errors.New("version not found")
+	ErrVersionNoServer = errors.New("this version has no server jar")
+)
+
+var catalogHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// piston-data 的網址裡面就有 sha1
+var pistonObjectRe = regexp.MustCompile(`/objects/([0-9a-f]{40})/`)
+
+type catalogSource struct {
+	kind        string
+	fetch       func() ([]model.MinecraftVersion, error)
+	mu          sync.Mutex // 同一個來源同時只抓一次
+	running     atomic.Bool
+	lastAttempt atomic.Int64 // unix 秒
+	lastErr     error        // 受 mu 保護
+}
+
+var catalogSources = map[string]*catalogSource{
+	CatalogVanilla:         {kind: CatalogVanilla, fetch: fetchVanillaVersions},
+	CatalogFabricGame:      {kind: CatalogFabricGame, fetch: fetchFabricGameVersions},
+	CatalogFabricLoader:    {kind: CatalogFabricLoader, fetch: fetchFabricLoaderVersions},
+	CatalogFabricInstaller: {kind: CatalogFabricInstaller, fetch: fetchFabricInstallerVersions},
+}
+
+func catalogRefreshInterval() time.Duration {
+	return time.Duration(max(common.VersionCatalogRefresh, 1)) * time.Minute
+}
+
+func catalogGet(url string, out any) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", common.UserAgent())
+	resp, err := catalogHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("get %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 32<<20)).Decode(out)
+}
+
+// ---------------- 來源 ----------------
+
+type vanillaManifest struct {
+	Versions []struct {
+		ID          string    `json:"id"`
+		Type        string    `json:"type"`
+		URL         string    `json:"url"`
+		SHA1        string    `json:"sha1"`
+		ReleaseTime time.Time `json:"releaseTime"`
+	} `json:"versions"`
+}
+
+// fetchVanillaVersions manifest 只有版本詳細資料的位址，server jar 等到要用時才解析
+// 詳細資料沒變的版本沿用已經解析過的 jar，沒解析過但內建清單有的先帶入
+func fetchVanillaVersions() ([]model.MinecraftVersion, error) {
+	var manifest vanillaManifest
+	if err := catalogGet(common.VanillaManifestURL, &manifest); err != nil {
+		return nil, err
+	}
+	if len(manifest.Versions) == 0 {
+		return nil, errors.New("vanilla manifest has no versions")
+	}
+	existing := map[string]model.MinecraftVersion{}
+	if old, err := model.GetVersions(CatalogVanilla); err == nil {
+		for _, v := range old {
+			existing[v.Version] = v
+		}
+	}
+	now := time.Now()
+	versions := make([]model.MinecraftVersion, 0, len(manifest.Versions))
+	for i, mv := range manifest.Versions {
+		v := model.MinecraftVersion{
+			Kind:       CatalogVanilla,
+			Version:    mv.ID,
+			Type:       mv.Type,
+			Stable:     mv.Type == "release",
+			Ordinal:    i,
+			ReleasedAt: mv.ReleaseTime,
+			MetaURL:    mv.URL,
+			MetaSHA1:   mv.SHA1,
+			FetchedAt:  now,
+		}
+		if old, ok := existing[mv.ID]; ok && old.ServerURL != "" && old.MetaSHA1 == mv.SHA1 {
+			v.ServerURL, v.ServerSHA1, v.ServerSize = old.ServerURL, old.ServerSHA1, old.ServerSize
+		} else if url, ok := common.VanillaServerUrl[mv.ID]; ok {
+			v.ServerURL, v.ServerSHA1 = url, pistonSHA1(url)
+		}
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+func pistonSHA1(url string) string {
+	if m := pistonObjectRe.FindStringSubmatch(url); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+func fetchFabricGameVersions() ([]model.MinecraftVersion, error) {
+	var gv []GameVersion
+	if err := catalogGet(common.FabricMetaURL+"/versions/game", &gv); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	versions := make([]model.MinecraftVersion, len(gv))
+	for i, v := range gv {
+		versions[i] = model.MinecraftVersion{Kind: CatalogFabricGame, Version: v.Version, Stable: v.Stable, Ordinal: i, FetchedAt: now}
+	}
+	return versions, nil
+}
+
+func fetchFabricLoaderVersions() ([]model.MinecraftVersion, error) {
+	return fetchFabricComponent(CatalogFabricLoader, "/versions/loader")
+}
+
+func fetchFabricInstallerVersions() ([]model.MinecraftVersion, error) {
+	return fetchFabricComponent(CatalogFabricInstaller, "/versions/installer")
+}
+
+// fetchFabricComponent loader 和 installer 的格式一樣，都是新的在前
+func fetchFabricComponent(kind, path string) ([]model.MinecraftVersion, error) {
+	var list []struct {
+		Version string `json:"version"`
+		Stable  bool   `json:"stable"`
+	}
+	if err := catalogGet(common.FabricMetaURL+path, &list); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	versions := make([]model.MinecraftVersion, len(list))
+	for i, v := range list {
+		versions[i] = model.MinecraftVersion{Kind: kind, Version: v.Version, Stable: v.Stable, Ordinal: i, FetchedAt: now}
+	}
+	return versions, nil
+}
+
+// ---------------- 快取 ----------------
+
+// refresh 抓到的清單是空的就不覆蓋舊資料
+func (s *catalogSource) refresh() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refreshLocked()
+}
+
+func (s *catalogSource) refreshLocked() error {
+	s.lastAttempt.Store(time.Now().Unix())
+	versions, err := s.fetch()
+	if err == nil && len(versions) == 0 {
+		err = errors.New("empty version list")
+	}
+	if err == nil {
+		err = model.ReplaceVersions(s.kind, versions)
+	}
+	if err != nil {
+		err = fmt.Errorf("refresh %s versions: %w", s.kind, err)
+	}
+	s.lastErr = err
+	return err
+}
+
+// refreshInBackground 已經在抓或剛失敗過就跳過
+func (s *catalogSource) refreshInBackground() {
+	if time.Since(time.Unix(s.lastAttempt.Load(), 0)) < catalogRetryInterval {
+		return
+	}
+	if !s.running.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer s.running.Store(false)
+		if err := s.refresh(); err != nil {
+			common.SysError(err.Error())
+		}
+	}()
+}
+
+// versions DB 有資料就直接回傳 (過期的話順便在背景更新)，完全沒有資料才等它抓完
+func (s *catalogSource) versions() ([]model.MinecraftVersion, error) {
+	versions, err := model.GetVersions(s.kind)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) > 0 {
+		if time.Since(versions[0].FetchedAt) > catalogRefreshInterval() {
+			s.refreshInBackground()
+		}
+		return versions, nil
+	}
+	// 其他 request 可能已經抓完了，拿到鎖之後再看一次；剛失敗過就直接回傳錯誤，不要每個 request 都等逾時
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	versions, err = model.GetVersions(s.kind)
+	if err != nil || len(versions) > 0 {
+		return versions, err
+	}
+	if s.lastErr != nil && time.Since(time.Unix(s.lastAttempt.Load(), 0)) < catalogRetryInterval {
+		return nil, s.lastErr
+	}
+	if err := s.refreshLocked(); err != nil {
+		return nil, err
+	}
+	return model.GetVersions(s.kind)
+}
+
+// StartVersionCatalog 啟動時只補抓沒有資料或過期的清單，之後定期全部更新
+func StartVersionCatalog() {
+	go func() {
+		for _, s := range catalogSources {
+			versions, err := model.GetVersions(s.kind)
+			if err == nil && len(versions) > 0 && time.Since(versions[0].FetchedAt) < catalogRefreshInterval() {
+				continue
+			}
+			if err := s.refresh(); err != nil {
+				common.SysError(err.Error())
+			}
+		}
+		ticker := time.NewTicker(catalogRefreshInterval())
+		defer ticker.Stop()
+		for range ticker.C {
+			for _, s := range catalogSources {
+				if err := s.refresh(); err != nil {
+					common.SysError(err.Error())
+				}
+			}
+		}
+	}()
+}
+
+// ListCatalogVersions kind 的完整清單，新的在前
+func ListCatalogVersions(kind string) ([]model.MinecraftVersion, error) {
+	s, ok := catalogSources[kind]
+	if !ok {
+		return nil, ErrUnknownCatalog
+	}
+	versions, err := s.versions()
+	if err != nil && kind == CatalogVanilla {
+		common.SysError(err.Error() + ", using bundled vanilla versions")
+		return bundledVanillaVersions(), nil
+	}
+	return versions, err
+}
+
+// bundledVanillaVersions 內建清單沒有順序，用版本號由新到舊排，快照這類解析不了的放最後
+func bundledVanillaVersions() []model.MinecraftVersion {
+	versions := make([]model.MinecraftVersion, 0, len(common.VanillaServerUrl))
+	for id, url := range common.VanillaServerUrl {
+		versions = append(versions, model.MinecraftVersion{
+			Kind:       CatalogVanilla,
+			Version:    id,
+			Stable:     !strings.Contains(id, "-") && !strings.Contains(id, "w"),
+			ServerURL:  url,
+			ServerSHA1: pistonSHA1(url),
+		})
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		a, b := parseMinecraftVersion(versions[i].Version), parseMinecraftVersion(versions[j].Version)
+		if (a == nil) != (b == nil) {
+			return a != nil
+		}
+		if c := compareVersion(a, b); c != 0 {
+			return c > 0
+		}
+		return versions[i].Version > versions[j].Version
+	})
+	return versions
+}
+
+// latestStable 第一個穩定版，都沒有就用最新的
+func latestStable(kind string) (string, error) {
+	versions, err := ListCatalogVersions(kind)
+	if err != nil {
+		return "", err
+	}
+	if len(versions) == 0 {
+		return "", fmt.Errorf("%w: %s is empty", ErrVersionNotFound, kind)
+	}
+	for _, v := range versions {
+		if v.Stable {
+			return v.Version, nil
+		}
+	}
+	return versions[0].Version, nil
+}
+
+// DefaultFabricLoader 有設定 LATEST_FABRIC_LOADER_VERSION 就用它，不然用清單裡最新的穩定版
+func DefaultFabricLoader() (string, error) {
+	if common.LatestFabricLoaderVersion != "" {
+		return common.LatestFabricLoaderVersion, nil
+	}
+	return latestStable(CatalogFabricLoader)
+}
+
+func DefaultFabricInstaller() (string, error) {
+	if common.LatestFabricInstallerVersion != "" {
+		return common.LatestFabricInstallerVersion, nil
+	}
+	return latestStable(CatalogFabricInstaller)
+}
+
+// VanillaServerJar 版本的 server jar 位址和 sha1，第一次用到時才去讀版本詳細資料並存起來
+func VanillaServerJar(version string) (string, string, error) {
+	v, err := model.GetVersion(CatalogVanilla, version)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 清單還沒抓過或抓不到，先用內建的
+		if _, lerr := ListCatalogVersions(CatalogVanilla); lerr == nil {
+			v, err = model.GetVersion(CatalogVanilla, version)
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if url, ok := common.VanillaServerUrl[version]; ok {
+				return url, pistonSHA1(url), nil
+			}
+			return "", "", fmt.Errorf("%w: %s", ErrVersionNotFound, version)
+		}
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if v.ServerURL != "" {
+		return v.ServerURL, v.ServerSHA1, nil
+	}
+	if v.MetaURL == "" {
+		return "", "", fmt.Errorf("%w: %s", ErrVersionNoServer, version)
+	}
+
+	var meta struct {
+		Downloads map[string]struct {
+			SHA1 string `json:"sha1"`
+			Size int64  `json:"size"`
+			URL  string `json:"url"`
+		} `json:"downloads"`
+	}
+	if err := catalogGet(v.MetaURL, &meta); err != nil {
+		if url, ok := common.VanillaServerUrl[version]; ok {
+			return url, pistonSHA1(url), nil
+		}
+		return "", "", err
+	}
+	server, ok := meta.Downloads["server"]
+	if !ok || server.URL == "" {
+		return "", "", fmt.Errorf("%w: %s", ErrVersionNoServer, version)
+	}
+	if err := model.SetVersionServer(CatalogVanilla, version, server.URL, server.SHA1, server.Size); err != nil {
+		common.SysError(fmt.Sprintf("save server jar of %s error: %s", version, err.Error()))
+	}
+	return server.URL, server.SHA1, nil
+}
@@ -0,0 +1,417 @@
+// service/worldTransfer.go
+// 世界的匯入與匯出：單人世界打包上傳後裝成伺服器的 level-name，匯出則直接串流成壓縮檔
+
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"go-backend/common"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// 壓縮檔最多幾個項目 (含資料夾)
+var worldImportMaxEntries = 200000
+
+var (
+	ErrWorldTooLarge = errors.New("world archive too large")
+	ErrInvalidWorld  = errors.New("invalid world archive")
+)
+
+// WorldImportResult 匯入結果
+type WorldImportResult struct {
+	LevelName string `json:"level_name"`
+	Source    string `json:"source"` // level.dat 在壓縮檔裡的資料夾，根目錄是 "."
+	Files     int    `json:"files"`
+	Size      int64  `json:"size"`
+}
+
+// worldExtractor 解壓時檢查路徑與總大小
+type worldExtractor struct {
+	root    string
+	limit   int64
+	size    int64
+	files   int
+	entries int // 含資料夾，用來限制項目數
+}
+
+func (x *worldExtractor) target(name string) (string, error) {
+	x.entries++
+	if x.entries > worldImportMaxEntries {
+		return "", fmt.Errorf("%w: too many files", ErrWorldTooLarge)
+	}
+	// Windows 打包的 zip 可能用反斜線
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") || strings.Contains(name, ":") {
+		return "", fmt.Errorf("%w: illegal path %s", ErrInvalidWorld, name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("%w: illegal path %s", ErrInvalidWorld, name)
+		}
+	}
+	return filepath.Join(x.root, filepath.FromSlash(path.Clean(name))), nil
+}
+
+func (x *worldExtractor) mkdir(name string) error {
+	target, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(target, 0755)
+}
+
+func (x *worldExtractor) write(name string, r io.Reader) error {
+	target, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	x.files++
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	// 不相信壓縮檔自己寫的大小，實際讀到超過就停
+	n, err := io.Copy(out, io.LimitReader(r, x.limit-x.size+1))
+	x.size += n
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if x.size > x.limit {
+		return ErrWorldTooLarge
+	}
+	return nil
+}
+
+func (x *worldExtractor) extractZip(archivePath string) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidWorld, err.Error())
+	}
+	defer zr.Close()
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			if err := x.mkdir(zf.Name); err != nil {
+				return err
+			}
+			continue
+		}
+		// symlink 之類的一律不收
+		if !zf.Mode().IsRegular() {
+			return fmt.Errorf("%w: unsupported entry %s", ErrInvalidWorld, zf.Name)
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidWorld, err.Error())
+		}
+		err = x.write(zf.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *worldExtractor) extractTar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidWorld, err.Error())
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = x.mkdir(hdr.Name)
+		case tar.TypeReg:
+			err = x.write(hdr.Name, tr)
+		case tar.TypeXGlobalHeader:
+			// pax 的全域 header，沒有內容
+		default:
+			err = fmt.Errorf("%w: unsupported entry %s", ErrInvalidWorld, hdr.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// extractWorldArchive 依檔頭判斷 zip / tar / tar.gz
+func extractWorldArchive(archivePath, dst string) (*worldExtractor, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	head, _ := br.Peek(262)
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return nil, err
+	}
+	x := &worldExtractor{root: filepath.Clean(dst), limit: int64(common.WorldExtractMaxSize) << 20}
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		err = x.extractZip(archivePath)
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidWorld, err.Error())
+		}
+		defer gz.Close()
+		err = x.extractTar(gz)
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		err = x.extractTar(br)
+	default:
+		return nil, fmt.Errorf("%w: expected zip, tar or tar.gz", ErrInvalidWorld)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// findWorldRoot 找最淺的 level.dat；同一層有好幾個的話 (Bukkit 的 world_nether 也有) 優先用跟 level-name 同名的
+func findWorldRoot(dir, levelName string) (string, error) {
+	var found []string
+	depth := -1
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != "level.dat" {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, filepath.Dir(p))
+		n := 0
+		if rel != "." {
+			n = strings.Count(filepath.ToSlash(rel), "/") + 1
+		}
+		switch {
+		case depth == -1 || n < depth:
+			depth, found = n, []string{rel}
+		case n == depth:
+			found = append(found, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(found) == 0 {
+		return "", fmt.Errorf("%w: level.dat not found", ErrInvalidWorld)
+	}
+	if len(found) == 1 {
+		return found[0], nil
+	}
+	for _, rel := range found {
+		if filepath.Base(rel) == levelName {
+			return rel, nil
+		}
+	}
+	return "", fmt.Errorf("%w: multiple worlds found", ErrInvalidWorld)
+}
+
+// ImportWorld 伺服器必須停止；整個解壓、驗證成功後才換掉現在的世界
+func (sm *ServerManager) ImportWorld(sid, workDir string, upload io.Reader) (*WorldImportResult, error) {
+	if sm.isAlive(sid) {
+		return nil, ErrServerRunning
+	}
+	unlock, err := lockBackup(sid)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	tmp, err := os.MkdirTemp(workDir, ".import-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	archivePath := filepath.Join(tmp, "upload")
+	out, err := os.Create(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	limit := int64(common.WorldUploadMaxSize) << 20
+	n, err := io.Copy(out, io.LimitReader(upload, limit+1))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n > limit {
+		return nil, ErrWorldTooLarge
+	}
+
+	extracted := filepath.Join(tmp, "extracted")
+	x, err := extractWorldArchive(archivePath, extracted)
+	if err != nil {
+		return nil, err
+	}
+	_ = os.Remove(archivePath)
+
+	level := levelName(workDir)
+	rel, err := findWorldRoot(extracted, level)
+	if err != nil {
+		return nil, err
+	}
+	_ = os.Remove(filepath.Join(extracted, rel, "session.lock"))
+
+	// 整理成 stage/<level-name>，再沿用備份還原的換世界流程
+	stage := filepath.Join(tmp, "stage")
+	if err := os.MkdirAll(stage, 0755); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(filepath.Join(extracted, rel), filepath.Join(stage, level)); err != nil {
+		return nil, err
+	}
+	if err := replaceWorlds(stage, workDir); err != nil {
+		return nil, err
+	}
+	return &WorldImportResult{
+		LevelName: level,
+		Source:    filepath.ToSlash(rel),
+		Files:     x.files,
+		Size:      x.size,
+	}, nil
+}
+
+// WorldExport 準備好要匯出的世界，用完要 Close
+type WorldExport struct {
+	LevelName string
+	srcDir    string
+	dirs      []string
+	cleanup   func()
+}
+
+// OpenWorldExport 伺服器在跑的話先做一次線上快照，匯出的是快照而不是正在寫入的檔案
+func (sm *ServerManager) OpenWorldExport(sid, workDir string) (*WorldExport, error) {
+	unlock, err := lockBackup(sid)
+	if err != nil {
+		return nil, err
+	}
+	level := levelName(workDir)
+
+	sm.mu.RLock()
+	srv, exists := sm.servers[sid]
+	sm.mu.RUnlock()
+
+	if !exists || !srv.IsAlive() {
+		dirs := worldDirs(workDir)
+		if len(dirs) == 0 {
+			unlock()
+			return nil, errors.New("world folder not found")
+		}
+		return &WorldExport{LevelName: level, srcDir: workDir, dirs: dirs, cleanup: unlock}, nil
+	}
+
+	if err := os.MkdirAll(backupDir(workDir), 0755); err != nil {
+		unlock()
+		return nil, err
+	}
+	snapDir, err := os.MkdirTemp(backupDir(workDir), ".snapshot-")
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	cleanup := func() {
+		_ = os.RemoveAll(snapDir)
+		unlock()
+	}
+	if err := srv.hotSnapshot(snapDir); err != nil {
+		cleanup()
+		return nil, err
+	}
+	return &WorldExport{LevelName: level, srcDir: snapDir, dirs: worldDirs(snapDir), cleanup: cleanup}, nil
+}
+
+// WriteTo format 是 "zip" 或 "tar.gz"
+func (e *WorldExport) WriteTo(w io.Writer, format string) error {
+	switch format {
+	case "zip":
+		_, err := writeWorldArchive(e.srcDir, e.dirs, w)
+		return err
+	case "tar.gz":
+		return writeWorldTar(e.srcDir, e.dirs, w)
+	default:
+		return fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+func (e *WorldExport) Close() {
+	e.cleanup()
+}
+
+func writeWorldTar(srcDir string, dirs []string, dst io.Writer) error {
+	gz := gzip.NewWriter(dst)
+	tw := tar.NewWriter(gz)
+	for _, dir := range dirs {
+		err := filepath.WalkDir(filepath.Join(srcDir, dir), func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && (!d.Type().IsRegular() || d.Name() == "session.lock") {
+				return nil
+			}
+			rel, err := filepath.Rel(srcDir, p)
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			hdr, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			hdr.Name = filepath.ToSlash(rel)
+			if d.IsDir() {
+				hdr.Name += "/"
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.CopyN(tw, f, hdr.Size)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
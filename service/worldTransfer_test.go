@@ -0,0 +1,185 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"go-backend/common"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type archiveEntry struct {
+	name    string
+	body    string
+	dir     bool
+	symlink string // 非空代表是指向這裡的 symlink
+}
+
+func buildZip(t *testing.T, entries []archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		body := e.body
+		switch {
+		case e.dir:
+			hdr.Name += "/"
+			hdr.SetMode(os.ModeDir | 0755)
+		case e.symlink != "":
+			hdr.SetMode(os.ModeSymlink | 0777)
+			body = e.symlink
+		default:
+			hdr.SetMode(0644)
+		}
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func buildTarGz(t *testing.T, entries []archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.body)), Typeflag: tar.TypeReg}
+		switch {
+		case e.dir:
+			hdr.Typeflag, hdr.Mode, hdr.Size = tar.TypeDir, 0755, 0
+		case e.symlink != "":
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.symlink, 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(e.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractWorldArchive(t *testing.T) {
+	oldSize, oldEntries := common.WorldExtractMaxSize, worldImportMaxEntries
+	common.WorldExtractMaxSize, worldImportMaxEntries = 1, 10 // 1 MB、10 個項目
+	defer func() { common.WorldExtractMaxSize, worldImportMaxEntries = oldSize, oldEntries }()
+	big := string(bytes.Repeat([]byte("a"), 2<<20))
+	manyEntries := []archiveEntry{}
+	for i := 0; i < 11; i++ {
+		manyEntries = append(manyEntries, archiveEntry{name: "world/d" + string(rune('a'+i)), dir: true})
+	}
+
+	tests := []struct {
+		name    string
+		entries []archiveEntry
+		want    error // nil 代表要成功
+	}{
+		{"valid world", []archiveEntry{{name: "world", dir: true}, {name: "world/level.dat", body: "x"}}, nil},
+		{"zip slip", []archiveEntry{{name: "../evil", body: "x"}}, ErrInvalidWorld},
+		{"nested zip slip", []archiveEntry{{name: "world/../../evil", body: "x"}}, ErrInvalidWorld},
+		{"absolute path", []archiveEntry{{name: "/etc/evil", body: "x"}}, ErrInvalidWorld},
+		{"drive letter", []archiveEntry{{name: "C:/evil", body: "x"}}, ErrInvalidWorld},
+		{"backslash slip", []archiveEntry{{name: "world\\..\\..\\evil", body: "x"}}, ErrInvalidWorld},
+		{"backslash drive", []archiveEntry{{name: "C:\\evil", body: "x"}}, ErrInvalidWorld},
+		{"symlink entry", []archiveEntry{{name: "world/link", symlink: "/etc/passwd"}}, ErrInvalidWorld},
+		{"too large", []archiveEntry{{name: "world/region.mca", body: big}}, ErrWorldTooLarge},
+		{"too many entries", manyEntries, ErrWorldTooLarge},
+	}
+
+	formats := map[string]func(*testing.T, []archiveEntry) []byte{"zip": buildZip, "tar.gz": buildTarGz}
+	for format, build := range formats {
+		for _, tt := range tests {
+			t.Run(format+"/"+tt.name, func(t *testing.T) {
+				dir := t.TempDir()
+				archive := filepath.Join(dir, "upload")
+				if err := os.WriteFile(archive, build(t, tt.entries), 0644); err != nil {
+					t.Fatal(err)
+				}
+				dst := filepath.Join(dir, "out")
+				_, err := extractWorldArchive(archive, dst)
+				if tt.want == nil {
+					if err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+				} else if !errors.Is(err, tt.want) {
+					t.Fatalf("expected %v, got %v", tt.want, err)
+				}
+				// 不管成功或失敗都不能寫到 dst 外面
+				if _, err := os.Stat(filepath.Join(dir, "evil")); !os.IsNotExist(err) {
+					t.Fatal("file written outside of the extraction root")
+				}
+			})
+		}
+	}
+}
+
+func TestExtractWorldArchiveUnknownFormat(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "upload")
+	if err := os.WriteFile(archive, []byte("not an archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := extractWorldArchive(archive, filepath.Join(dir, "out")); !errors.Is(err, ErrInvalidWorld) {
+		t.Fatalf("expected ErrInvalidWorld, got %v", err)
+	}
+}
+
+func TestFindWorldRoot(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		level string
+		want  string
+		err   bool
+	}{
+		{"root level.dat", []string{"level.dat"}, "world", ".", false},
+		{"nested folder", []string{"My World/level.dat", "My World/region/r.0.0.mca"}, "world", "My World", false},
+		{"shallowest wins", []string{"a/level.dat", "a/b/level.dat"}, "world", "a", false},
+		{"prefer level-name", []string{"world/level.dat", "world_nether/level.dat"}, "world", "world", false},
+		{"ambiguous", []string{"one/level.dat", "two/level.dat"}, "world", "", true},
+		{"missing level.dat", []string{"world/region/r.0.0.mca"}, "world", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range tt.files {
+				mustWrite(t, filepath.Join(dir, filepath.FromSlash(f)), "x")
+			}
+			got, err := findWorldRoot(dir, tt.level)
+			if tt.err {
+				if !errors.Is(err, ErrInvalidWorld) {
+					t.Fatalf("expected ErrInvalidWorld, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if filepath.ToSlash(got) != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}